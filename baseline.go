@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// readBaseline reads the findings of a previous JSON result, with their fingerprints.
+// A missing file is an empty baseline so that -updateBaseline can create it.
+func readBaseline(path string) ([]Finding, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var baseline struct {
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.NewDecoder(file).Decode(&baseline); err != nil {
+		return nil, err
+	}
+
+	for i, f := range baseline.Findings {
+		if f.Fingerprint == "" {
+			baseline.Findings[i].Fingerprint = fingerprint(f)
+		}
+		if f.PathFingerprint == "" && f.Commit != "" {
+			baseline.Findings[i].PathFingerprint = pathFingerprint(f)
+		}
+	}
+	return baseline.Findings, nil
+}
+
+// loadBaseline reads the fingerprints of the findings in a previous JSON result. The path
+// fingerprints are included, so that a finding without a commit for the same secret and path as
+// a finding in the baseline is known too, e.g. when an incremental run doesn't scan that commit.
+func loadBaseline(path string) (map[string]bool, error) {
+	findings, err := readBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, f := range findings {
+		known[f.Fingerprint] = true
+		if f.PathFingerprint != "" {
+			known[f.PathFingerprint] = true
+		}
+	}
+	return known, nil
+}
+
+// applyBaseline marks the findings that are in the baseline as known. Known findings are
+// dropped unless showKnown is set.
+func applyBaseline(findings []Finding, known map[string]bool, showKnown bool) []Finding {
+	var filtered []Finding
+	for _, f := range findings {
+		if known[f.Fingerprint] {
+			if !showKnown {
+				continue
+			}
+			f.Known = true
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// writeBaseline saves the findings of the current run as the baseline for the next one.
+// Only the fingerprints are needed to match findings, so the secrets themselves are left out.
+// The findings already in the baseline that keep returns true for are kept as well. That is
+// needed for the repositories the run didn't scan completely, like the ones that failed or the
+// commits that were skipped with -stateFile, so that their triaged findings aren't reported again.
+func writeBaseline(path string, findings []Finding, keep func(f Finding) bool) error {
+	previous, err := readBaseline(path)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool)
+	for _, f := range findings {
+		current[f.Fingerprint] = true
+	}
+	for _, f := range previous {
+		if !current[f.Fingerprint] && keep(f) {
+			findings = append(findings, f)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var baseline []Finding
+	for _, f := range findings {
+		f.Secret = ""
+		f.Known = false
+		baseline = append(baseline, f)
+	}
+	return writeJSON(file, baseline, nil)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bitbucketProvider lists repositories on Bitbucket Cloud or a Bitbucket Server instance.
+// On Bitbucket Cloud, workspaces take the place of Github organizations. On Bitbucket Server,
+// that is done by projects, which are referred to by their key.
+type bitbucketProvider struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	server  bool
+}
+
+// bitbucketLinks is the set of clone links both Bitbucket Cloud and Server return for a repository
+type bitbucketLinks struct {
+	Clone []struct {
+		Name string `json:"name"`
+		Href string `json:"href"`
+	} `json:"clone"`
+}
+
+func (l bitbucketLinks) cloneURLs() (httpsURL string, sshURL string) {
+	for _, c := range l.Clone {
+		switch c.Name {
+		case "https", "http":
+			httpsURL = c.Href
+		case "ssh":
+			sshURL = c.Href
+		}
+	}
+	return httpsURL, sshURL
+}
+
+type bitbucketCloudRepo struct {
+	Slug      string         `json:"slug"`
+	IsPrivate bool           `json:"is_private"`
+	UpdatedOn time.Time      `json:"updated_on"`
+	Links     bitbucketLinks `json:"links"`
+	Parent    *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+}
+
+type bitbucketCloudSnippet struct {
+	ID        string         `json:"id"`
+	IsPrivate bool           `json:"is_private"`
+	Links     bitbucketLinks `json:"links"`
+}
+
+type bitbucketCloudMember struct {
+	User struct {
+		UUID string `json:"uuid"`
+	} `json:"user"`
+}
+
+type bitbucketServerRepo struct {
+	Slug   string         `json:"slug"`
+	Public bool           `json:"public"`
+	Links  bitbucketLinks `json:"links"`
+	Origin *struct {
+		Slug string `json:"slug"`
+	} `json:"origin"`
+}
+
+type bitbucketServerPermission struct {
+	User struct {
+		Slug string `json:"slug"`
+	} `json:"user"`
+}
+
+// newBitbucketProvider returns a provider for Bitbucket Cloud if baseURL is api.bitbucket.org,
+// or for the Bitbucket Server instance at baseURL otherwise
+func newBitbucketProvider(client *http.Client, baseURL string, token string) *bitbucketProvider {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	p := &bitbucketProvider{client: client, token: token}
+
+	if u, err := url.Parse(baseURL); err == nil && u.Host == "api.bitbucket.org" {
+		p.baseURL = strings.TrimSuffix(baseURL, "/2.0") + "/2.0"
+	} else {
+		p.server = true
+		p.baseURL = strings.TrimSuffix(baseURL, "/rest/api/1.0") + "/rest/api/1.0"
+	}
+	return p
+}
+
+// headers returns the authentication headers. A token in the form username:password, like a
+// Bitbucket Cloud app password, is sent with basic auth and anything else as a bearer token.
+func (p *bitbucketProvider) headers() map[string]string {
+	if p.token == "" {
+		return nil
+	}
+	if strings.Contains(p.token, ":") {
+		return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(p.token))}
+	}
+	return map[string]string{"Authorization": "Bearer " + p.token}
+}
+
+// bitbucketCloudPage is a page of results from the Bitbucket Cloud API
+type bitbucketCloudPage struct {
+	Values json.RawMessage `json:"values"`
+	Next   string          `json:"next"`
+}
+
+// bitbucketServerPage is a page of results from the Bitbucket Server API
+type bitbucketServerPage struct {
+	Values        json.RawMessage `json:"values"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+// list requests every page of the Bitbucket API endpoint at path and calls add with the values of
+// each page. Bitbucket Cloud links to the next page, while Bitbucket Server returns the start of it.
+func (p *bitbucketProvider) list(ctx context.Context, path string, query url.Values, add func(values json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	if !p.server {
+		query.Set("pagelen", "100")
+		next := p.baseURL + path + "?" + query.Encode()
+		for next != "" {
+			var page bitbucketCloudPage
+			if _, err := getJSON(ctx, p.client, next, p.headers(), &page); err != nil {
+				return err
+			}
+			if err := add(page.Values); err != nil {
+				return err
+			}
+			next = page.Next
+		}
+		return nil
+	}
+
+	query.Set("limit", "100")
+	query.Set("start", "0")
+	for {
+		var page bitbucketServerPage
+		if _, err := getJSON(ctx, p.client, p.baseURL+path+"?"+query.Encode(), p.headers(), &page); err != nil {
+			return err
+		}
+		if err := add(page.Values); err != nil {
+			return err
+		}
+		if page.IsLastPage {
+			return nil
+		}
+		query.Set("start", strconv.Itoa(page.NextPageStart))
+	}
+}
+
+// repos lists the repositories at path
+func (p *bitbucketProvider) repos(ctx context.Context, path string, query url.Values, owner string) ([]Repo, error) {
+	var repos []Repo
+	err := p.list(ctx, path, query, func(values json.RawMessage) error {
+		if p.server {
+			var page []bitbucketServerRepo
+			if err := json.Unmarshal(values, &page); err != nil {
+				return err
+			}
+			for _, r := range page {
+				httpsURL, sshURL := r.Links.cloneURLs()
+				repos = append(repos, Repo{
+					Name:     r.Slug,
+					Owner:    owner,
+					CloneURL: httpsURL,
+					SSHURL:   sshURL,
+					Fork:     r.Origin != nil,
+					Private:  !r.Public,
+				})
+			}
+			return nil
+		}
+
+		var page []bitbucketCloudRepo
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, r := range page {
+			httpsURL, sshURL := r.Links.cloneURLs()
+			repos = append(repos, Repo{
+				Name:     r.Slug,
+				Owner:    owner,
+				CloneURL: httpsURL,
+				SSHURL:   sshURL,
+				Fork:     r.Parent != nil,
+				Private:  r.IsPrivate,
+				PushedAt: r.UpdatedOn,
+			})
+		}
+		return nil
+	})
+	return repos, err
+}
+
+// OrgRepos lists the repositories of a Bitbucket Cloud workspace or a Bitbucket Server project
+func (p *bitbucketProvider) OrgRepos(ctx context.Context, org string) ([]Repo, error) {
+	if p.server {
+		return p.repos(ctx, "/projects/"+url.PathEscape(org)+"/repos", nil, org)
+	}
+	return p.repos(ctx, "/repositories/"+url.PathEscape(org), nil, org)
+}
+
+// OrgMembers lists the members of a Bitbucket Cloud workspace, or the users with an explicit
+// permission on a Bitbucket Server project. Bitbucket Cloud members are listed by their UUID, which
+// the API accepts in place of the slug of their personal workspace.
+func (p *bitbucketProvider) OrgMembers(ctx context.Context, org string) ([]string, error) {
+	var members []string
+
+	if p.server {
+		err := p.list(ctx, "/projects/"+url.PathEscape(org)+"/permissions/users", nil, func(values json.RawMessage) error {
+			var page []bitbucketServerPermission
+			if err := json.Unmarshal(values, &page); err != nil {
+				return err
+			}
+			for _, perm := range page {
+				members = append(members, perm.User.Slug)
+			}
+			return nil
+		})
+		return members, err
+	}
+
+	err := p.list(ctx, "/workspaces/"+url.PathEscape(org)+"/members", nil, func(values json.RawMessage) error {
+		var page []bitbucketCloudMember
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, m := range page {
+			members = append(members, m.User.UUID)
+		}
+		return nil
+	})
+	return members, err
+}
+
+// UserRepos lists the repositories in the personal workspace or personal project of user
+func (p *bitbucketProvider) UserRepos(ctx context.Context, user string) ([]Repo, error) {
+	if !p.server {
+		var query url.Values
+		if *scanPrivateReposOnly {
+			query = url.Values{"q": {"is_private=true"}}
+		}
+		return p.repos(ctx, "/repositories/"+url.PathEscape(user), query, user)
+	}
+
+	repos, err := p.repos(ctx, "/users/"+url.PathEscape(user)+"/repos", nil, user)
+	if err != nil || !*scanPrivateReposOnly {
+		return repos, err
+	}
+
+	var private []Repo
+	for _, r := range repos {
+		if r.Private {
+			private = append(private, r)
+		}
+	}
+	return private, nil
+}
+
+// UserGists lists the snippets in the personal workspace of user. Bitbucket Server has no snippets.
+func (p *bitbucketProvider) UserGists(ctx context.Context, user string) ([]Repo, error) {
+	if p.server {
+		return nil, nil
+	}
+
+	var snippets []Repo
+	err := p.list(ctx, "/snippets/"+url.PathEscape(user), nil, func(values json.RawMessage) error {
+		var page []bitbucketCloudSnippet
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, s := range page {
+			if *scanPrivateReposOnly && !s.IsPrivate {
+				continue
+			}
+			httpsURL, sshURL := s.Links.cloneURLs()
+			snippets = append(snippets, Repo{
+				Name:     s.ID,
+				Owner:    user,
+				CloneURL: httpsURL,
+				SSHURL:   sshURL,
+				Private:  s.IsPrivate,
+			})
+		}
+		return nil
+	})
+	return snippets, err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBitbucketCloud(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Bitbucket Cloud links to the next page
+		switch r.URL.Path {
+		case "/2.0/repositories/acme":
+			if r.URL.Query().Get("page") == "" {
+				fmt.Fprintf(w, `{"values":[{"slug":"api","is_private":true,"links":{"clone":[{"name":"https","href":"https://bitbucket.org/acme/api.git"},{"name":"ssh","href":"git@bitbucket.org:acme/api.git"}]}}],"next":"%s/2.0/repositories/acme?page=2"}`, srv.URL)
+				return
+			}
+			fmt.Fprint(w, `{"values":[{"slug":"web","parent":{"full_name":"other/web"},"links":{"clone":[{"name":"https","href":"https://bitbucket.org/acme/web.git"}]}}]}`)
+		case "/2.0/workspaces/acme/members":
+			fmt.Fprint(w, `{"values":[{"user":{"uuid":"{11111111-2222-3333-4444-555555555555}","nickname":"Alice Smith"}}]}`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// the host decides between Cloud and Server, so the fake API is only reached through the client
+	p := newBitbucketProvider(srv.Client(), "https://api.bitbucket.org", "secret")
+	p.baseURL = srv.URL + "/2.0"
+
+	repos, err := p.OrgRepos(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "api" || repos[1].Name != "web" {
+		t.Fatalf("got repos %+v, want api and web from both pages", repos)
+	}
+	if !repos[0].Private || repos[0].SSHURL != "git@bitbucket.org:acme/api.git" || !repos[1].Fork {
+		t.Errorf("private, clone links or fork weren't mapped: %+v", repos)
+	}
+
+	// members are listed by UUID, which /repositories/{workspace} accepts, and not by nickname
+	members, err := p.OrgMembers(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0] != "{11111111-2222-3333-4444-555555555555}" {
+		t.Errorf("got members %v, want the UUID of alice", members)
+	}
+}
+
+func TestBitbucketServer(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Bitbucket Server returns the start of the next page until the last one
+		switch r.URL.Path + "?" + r.URL.Query().Get("start") {
+		case "/rest/api/1.0/projects/PRJ/repos?0":
+			fmt.Fprint(w, `{"values":[{"slug":"api","public":false,"links":{"clone":[{"name":"http","href":"https://bitbucket.example.com/scm/prj/api.git"}]}}],"isLastPage":false,"nextPageStart":25}`)
+		case "/rest/api/1.0/projects/PRJ/repos?25":
+			fmt.Fprint(w, `{"values":[{"slug":"web","public":true,"origin":{"slug":"web"},"links":{"clone":[{"name":"ssh","href":"ssh://git@bitbucket.example.com:7999/prj/web.git"}]}}],"isLastPage":true}`)
+		case "/rest/api/1.0/projects/PRJ/permissions/users?0":
+			fmt.Fprint(w, `{"values":[{"user":{"slug":"alice"}},{"user":{"slug":"bob"}}],"isLastPage":true}`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := newBitbucketProvider(srv.Client(), srv.URL, "secret")
+
+	repos, err := p.OrgRepos(context.Background(), "PRJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "api" || repos[1].Name != "web" {
+		t.Fatalf("got repos %+v, want api and web from both pages", repos)
+	}
+	if !repos[0].Private || repos[1].Private || !repos[1].Fork || repos[1].SSHURL == "" {
+		t.Errorf("private, clone links or fork weren't mapped: %+v", repos)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+
+	members, err := p.OrgMembers(context.Background(), "PRJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0] != "alice" || members[1] != "bob" {
+		t.Errorf("got members %v, want [alice bob]", members)
+	}
+}
+
+func TestBitbucketAppPassword(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"values":[],"isLastPage":true}`)
+	}))
+	defer srv.Close()
+
+	if _, err := newBitbucketProvider(srv.Client(), srv.URL, "alice:app-password").OrgRepos(context.Background(), "PRJ"); err != nil {
+		t.Error(err)
+	}
+}
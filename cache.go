@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// mirrorLocks makes sure that a mirror is only fetched by one clone at a time, e.g. when the same
+// repository is listed with both its HTTPS and SSH URL
+var mirrorLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+func lockMirror(path string) func() {
+	mirrorLocks.Lock()
+	l, ok := mirrorLocks.m[path]
+	if !ok {
+		l = &sync.Mutex{}
+		mirrorLocks.m[path] = l
+	}
+	mirrorLocks.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// mirrorPath returns where the bare mirror of the repository at cloneURL is kept in the cache.
+// Mirrors are keyed by repoKey, so that the HTTPS and SSH URLs of a repository share the same mirror.
+func mirrorPath(cacheDir string, cloneURL string) (string, error) {
+	key, err := repoKey(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, strings.Replace(key, "..", "_", -1)+".git"), nil
+}
+
+// mirrorRefSpecs are the refs that are kept in a mirror. Only the branches and tags are scanned,
+// so the pull request and merge request refs that Github and Gitlab also have aren't fetched.
+var mirrorRefSpecs = []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+
+// updateMirror fetches the branches and tags of the repository at cloneURL into its mirror in the
+// cache, or creates the mirror if there isn't one yet. It returns the path of the mirror.
+func updateMirror(cacheDir string, cloneURL string) (string, error) {
+	mirror, err := mirrorPath(cacheDir, cloneURL)
+	if err != nil {
+		return "", err
+	}
+	defer lockMirror(mirror)()
+
+	auth, err := cloneAuth(cloneURL)
+	if err != nil {
+		return "", err
+	}
+
+	created := false
+	repo, err := git.PlainOpen(mirror)
+	if err == git.ErrRepositoryNotExists {
+		created = true
+		repo, err = git.PlainInit(mirror, true)
+	}
+	if err == nil {
+		err = fetchMirror(repo, cloneURL, auth)
+	}
+	if err != nil && created {
+		os.RemoveAll(mirror)
+	}
+	return mirror, err
+}
+
+// fetchMirror fetches the branches and tags of the repository at cloneURL into repo, and points
+// its HEAD at the default branch of the remote, so that clones of the mirror check that out
+func fetchMirror(repo *git.Repository, cloneURL string, auth transport.AuthMethod) error {
+	remote := git.NewRemote(repo.Storer, &config.RemoteConfig{Name: "origin", URLs: []string{cloneURL}})
+
+	err := remote.Fetch(&git.FetchOptions{
+		Auth:     auth,
+		RefSpecs: mirrorRefSpecs,
+		Force:    true,
+		Prune:    true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref.Target()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// secretHash returns the SHA-256 of a secret, so that it can be compared without being stored
+func secretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint identifies a leak by the hash of the secret and the repository, path and commit it
+// was found in. The owner isn't part of it so that every clone of a fork has the same fingerprint.
+func fingerprint(f Finding) string {
+	sum := sha256.Sum256([]byte(secretHash(f.Secret) + "\x00" + f.Repo + "\x00" + f.File + "\x00" + f.Commit))
+	return hex.EncodeToString(sum[:])
+}
+
+// pathFingerprint is the fingerprint the same secret in the same path has when it is found by a
+// tool that only looks at the checked out files. Those findings are folded into the finding with a
+// commit, so the baseline keeps this as well to recognize them once the commit isn't scanned anymore.
+func pathFingerprint(f Finding) string {
+	f.Commit = ""
+	return fingerprint(f)
+}
+
+// mergeFinding records the tools that found dup on f
+func mergeFinding(f *Finding, dup Finding) {
+	for _, tool := range dup.Tools {
+		found := false
+		for _, t := range f.Tools {
+			if t == tool {
+				found = true
+				break
+			}
+		}
+		if !found {
+			f.Tools = append(f.Tools, tool)
+		}
+	}
+	sort.Strings(f.Tools)
+}
+
+// dedupFindings merges the findings that have the same fingerprint, keeping the first one and
+// recording every tool that contributed to it. Tools like git-secrets and repo-supervisor only look
+// at the checked out files, so their findings have no commit. Those are merged into a finding for
+// the same secret and path that does have a commit, if there is one.
+// The findings are sorted first, so that the finding that is kept for clones of a fork or for
+// several tools doesn't depend on which one was scanned first.
+func dedupFindings(findings []Finding) []Finding {
+	findings = append([]Finding(nil), findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Commit != b.Commit {
+			return a.Commit < b.Commit
+		}
+		if a.OrgOrUser != b.OrgOrUser {
+			return a.OrgOrUser < b.OrgOrUser
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Line < b.Line
+	})
+
+	var merged []*Finding
+	byFingerprint := make(map[string]*Finding)
+
+	for _, f := range findings {
+		f.Fingerprint = fingerprint(f)
+		if f.Commit != "" {
+			f.PathFingerprint = pathFingerprint(f)
+		}
+		if f.Tools == nil {
+			f.Tools = []string{f.Tool}
+		}
+
+		if existing, ok := byFingerprint[f.Fingerprint]; ok {
+			mergeFinding(existing, f)
+			continue
+		}
+		nf := f
+		byFingerprint[f.Fingerprint] = &nf
+		merged = append(merged, &nf)
+	}
+
+	// fold the findings without a commit into one with a commit for the same secret, repo and path
+	withCommit := make(map[string]*Finding)
+	for _, f := range merged {
+		if f.Commit == "" {
+			continue
+		}
+		key := secretHash(f.Secret) + "\x00" + f.Repo + "\x00" + f.File
+		if _, ok := withCommit[key]; !ok {
+			withCommit[key] = f
+		}
+	}
+
+	folded := make(map[*Finding]bool)
+	for _, f := range merged {
+		if f.Commit != "" {
+			continue
+		}
+		if target, ok := withCommit[secretHash(f.Secret)+"\x00"+f.Repo+"\x00"+f.File]; ok {
+			mergeFinding(target, *f)
+			folded[f] = true
+		}
+	}
+
+	var deduped []Finding
+	for _, f := range merged {
+		if !folded[f] {
+			deduped = append(deduped, *f)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+	hexChars    = "1234567890abcdefABCDEF"
+)
+
+// entropyScanner looks for high entropy base64 and hex strings in every line added to a
+// repository's history, the same way truffleHog's --entropy mode does, but without Python.
+type entropyScanner struct{}
+
+func init() {
+	registerScanner(entropyScanner{})
+}
+
+func (entropyScanner) Name() string { return "entropy" }
+
+func (entropyScanner) Scan(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error) {
+	return runEntropy(filepath, reponame, orgoruser, commits)
+}
+
+// shannonEntropy calculates the Shannon entropy of data over the characters in charset
+func shannonEntropy(data string, charset string) float64 {
+	if data == "" {
+		return 0
+	}
+
+	entropy := 0.0
+	for _, c := range charset {
+		px := float64(strings.Count(data, string(c))) / float64(len(data))
+		if px > 0 {
+			entropy += -px * math.Log2(px)
+		}
+	}
+	return entropy
+}
+
+// charsetStrings returns the runs of characters from charset in word that are at least minLength long
+func charsetStrings(word string, charset string, minLength int) []string {
+	var found []string
+	start := -1
+
+	for i := 0; i <= len(word); i++ {
+		if i < len(word) && strings.IndexByte(charset, word[i]) != -1 {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 && i-start >= minLength {
+			found = append(found, word[start:i])
+		}
+		start = -1
+	}
+	return found
+}
+
+// entropyMatch is a string flagged by the entropy scanner along with the reason it was flagged
+type entropyMatch struct {
+	reason string
+	value  string
+}
+
+// highEntropyStrings returns the base64 and hex strings in line that are above the configured thresholds
+func highEntropyStrings(line string) []entropyMatch {
+	var found []entropyMatch
+
+	for _, word := range strings.Fields(line) {
+		for _, s := range charsetStrings(word, base64Chars, *entropyMinLength) {
+			if shannonEntropy(s, base64Chars) > *entropyB64Threshold {
+				found = append(found, entropyMatch{"High Entropy base64", s})
+			}
+		}
+		for _, s := range charsetStrings(word, hexChars, *entropyMinLength) {
+			if shannonEntropy(s, hexChars) > *entropyHexThreshold {
+				found = append(found, entropyMatch{"High Entropy hex", s})
+			}
+		}
+	}
+	return found
+}
+
+func runEntropy(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error) {
+	var findings []Finding
+
+	err := scanLines(filepath, commits, func(l historyLine) {
+		for _, m := range highEntropyStrings(l.Text) {
+			findings = append(findings, lineFinding("entropy", m.reason, reponame, orgoruser, l, m.value))
+		}
+	})
+	return findings, err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Finding is a single secret found by one of the tools
+type Finding struct {
+	Tool      string `json:"tool"`
+	Rule      string `json:"rule"`
+	OrgOrUser string `json:"orgoruser"`
+	Repo      string `json:"repo"`
+	RepoURL   string `json:"repoURL,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Secret    string `json:"secret"`
+	Preview   string `json:"preview"`
+
+	// Tools and Fingerprint are set when the findings of all the tools are deduplicated.
+	// PathFingerprint is set for findings with a commit, see pathFingerprint.
+	Tools           []string `json:"tools,omitempty"`
+	Fingerprint     string   `json:"fingerprint,omitempty"`
+	PathFingerprint string   `json:"pathFingerprint,omitempty"`
+
+	// Known is set for findings that are in the baseline when -showKnown is used
+	Known bool `json:"known,omitempty"`
+}
+
+// scanError is an error that stopped a repository from being listed, cloned or scanned by a tool.
+// These are collected so that a single broken repository doesn't stop the whole run.
+type scanError struct {
+	Stage     string `json:"stage"`
+	Tool      string `json:"tool,omitempty"`
+	OrgOrUser string `json:"orgoruser,omitempty"`
+	Repo      string `json:"repo,omitempty"`
+	Error     string `json:"error"`
+}
+
+// resultStore collects the findings and errors of every scan
+type resultStore struct {
+	sync.Mutex
+	findings []Finding
+	errors   []scanError
+}
+
+var results resultStore
+
+func (r *resultStore) addFindings(findings []Finding) {
+	r.Lock()
+	defer r.Unlock()
+	r.findings = append(r.findings, findings...)
+}
+
+// addError records an error for a stage of the run. tool, orgoruser and reponame are left
+// empty when they don't apply, for example when listing the repositories of an org fails.
+func (r *resultStore) addError(stage string, tool string, orgoruser string, reponame string, err error) {
+	subject := orgoruser
+	if reponame != "" {
+		subject += "/" + reponame
+	}
+	if tool != "" {
+		subject += " (" + tool + ")"
+	}
+	log.Printf("%s failed for %s: %v", stage, subject, err)
+
+	r.Lock()
+	defer r.Unlock()
+	r.errors = append(r.errors, scanError{Stage: stage, Tool: tool, OrgOrUser: orgoruser, Repo: reponame, Error: err.Error()})
+}
+
+// failed reports whether listing, cloning or scanning the repository reponame of orgoruser failed,
+// either on its own or because the repositories of orgoruser couldn't be listed. The owner isn't
+// compared for errors of a repository, since the finding kept for clones of a fork can have
+// the owner of another clone.
+func (r *resultStore) failed(orgoruser string, reponame string) bool {
+	r.Lock()
+	defer r.Unlock()
+	for _, e := range r.errors {
+		if e.Repo == "" && (e.OrgOrUser == "" || e.OrgOrUser == orgoruser) {
+			return true
+		}
+		if e.Repo != "" && e.Repo == reponame {
+			return true
+		}
+	}
+	return false
+}
+
+// redact hides all but the first 4 characters of a secret
+func redact(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
+
+// lineFinding builds the Finding for a match in a line read by one of the native scanners
+func lineFinding(toolname string, rule string, reponame string, orgoruser string, l historyLine, match string) Finding {
+	f := Finding{
+		Tool:      toolname,
+		Rule:      rule,
+		OrgOrUser: orgoruser,
+		Repo:      reponame,
+		File:      l.Path,
+		Line:      l.Line,
+		Commit:    l.Commit,
+		Author:    l.Author,
+		Secret:    match,
+		Preview:   redact(match),
+	}
+	if !l.Date.IsZero() {
+		f.Date = l.Date.Format(time.RFC3339)
+	}
+	return f
+}
+
+// writeFindingText writes a finding in the same plain text layout the tools use
+func writeFindingText(w io.Writer, f Finding) {
+	fmt.Fprintf(w, "Reason: %s\n", f.Rule)
+	if f.Commit != "" {
+		fmt.Fprintf(w, "Commit: %s\nAuthor: %s\nDate: %s\n", f.Commit, f.Author, f.Date)
+	}
+	fmt.Fprintf(w, "Filepath: %s\n", f.File)
+	if f.Line > 0 {
+		fmt.Fprintf(w, "Line: %d\n", f.Line)
+	}
+	if len(f.Tools) > 1 {
+		fmt.Fprintf(w, "Tools: %s\n", strings.Join(f.Tools, ", "))
+	}
+	if f.Known {
+		fmt.Fprintf(w, "Known: yes\n")
+	}
+	fmt.Fprintf(w, "String: %s\n\n", f.Secret)
+}
+
+// writeErrorsText writes the errors section of the text output
+func writeErrorsText(w io.Writer, errors []scanError) {
+	if len(errors) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "Errors:\n")
+	for _, e := range errors {
+		fmt.Fprintf(w, "Stage: %s", e.Stage)
+		if e.Tool != "" {
+			fmt.Fprintf(w, " Tool: %s", e.Tool)
+		}
+		if e.OrgOrUser != "" {
+			fmt.Fprintf(w, " OrgorUser: %s", e.OrgOrUser)
+		}
+		if e.Repo != "" {
+			fmt.Fprintf(w, " RepoName: %s", e.Repo)
+		}
+		fmt.Fprintf(w, " Error: %s\n", e.Error)
+	}
+}
+
+// writeJSON writes the findings and errors as a single JSON document
+func writeJSON(w io.Writer, findings []Finding, errors []scanError) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Findings []Finding   `json:"findings"`
+		Errors   []scanError `json:"errors,omitempty"`
+	}{findings, errors})
+}
+
+// writeNDJSON writes the findings as newline delimited JSON, one finding per line.
+// Errors follow the findings, each wrapped in an object with a single "error" key.
+func writeNDJSON(w io.Writer, findings []Finding, errors []scanError) error {
+	enc := json.NewEncoder(w)
+	for _, f := range findings {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	for _, e := range errors {
+		if err := enc.Encode(struct {
+			Error scanError `json:"error"`
+		}{e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
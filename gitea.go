@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaProvider lists repositories on a Gitea or Forgejo instance, which share the same API
+type giteaProvider struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+type giteaRepo struct {
+	Name      string    `json:"name"`
+	CloneURL  string    `json:"clone_url"`
+	SSHURL    string    `json:"ssh_url"`
+	Fork      bool      `json:"fork"`
+	Private   bool      `json:"private"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Owner     struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+// newGiteaProvider returns a provider for the Gitea instance at baseURL, e.g. https://gitea.example.com
+func newGiteaProvider(client *http.Client, baseURL string, token string) *giteaProvider {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, "/api/v1") {
+		baseURL += "/api/v1"
+	}
+	return &giteaProvider{client: client, baseURL: baseURL, token: token}
+}
+
+// list requests every page of the Gitea API endpoint at path and calls add with each page.
+// The instance may return fewer items per page than requested, so listing stops at the first
+// empty page.
+func (p *giteaProvider) list(ctx context.Context, path string, page func() interface{}, add func(v interface{}) int) error {
+	query := url.Values{"limit": {"50"}}
+
+	headers := map[string]string{}
+	if p.token != "" {
+		headers["Authorization"] = "token " + p.token
+	}
+
+	for n := 1; ; n++ {
+		query.Set("page", strconv.Itoa(n))
+		v := page()
+		if _, err := getJSON(ctx, p.client, p.baseURL+path+"?"+query.Encode(), headers, v); err != nil {
+			return err
+		}
+		if add(v) == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *giteaProvider) repos(ctx context.Context, path string) ([]Repo, error) {
+	var repos []Repo
+	err := p.list(ctx, path, func() interface{} { return &[]giteaRepo{} }, func(v interface{}) int {
+		page := *v.(*[]giteaRepo)
+		for _, r := range page {
+			repos = append(repos, Repo{
+				Name:     r.Name,
+				Owner:    r.Owner.Login,
+				CloneURL: r.CloneURL,
+				SSHURL:   r.SSHURL,
+				Fork:     r.Fork,
+				Private:  r.Private,
+				PushedAt: r.UpdatedAt,
+			})
+		}
+		return len(page)
+	})
+	return repos, err
+}
+
+func (p *giteaProvider) OrgRepos(ctx context.Context, org string) ([]Repo, error) {
+	return p.repos(ctx, "/orgs/"+url.PathEscape(org)+"/repos")
+}
+
+func (p *giteaProvider) OrgMembers(ctx context.Context, org string) ([]string, error) {
+	var members []string
+	err := p.list(ctx, "/orgs/"+url.PathEscape(org)+"/members", func() interface{} { return &[]giteaUser{} }, func(v interface{}) int {
+		page := *v.(*[]giteaUser)
+		for _, u := range page {
+			members = append(members, u.Login)
+		}
+		return len(page)
+	})
+	return members, err
+}
+
+// UserRepos lists the repositories of user. With -scanPrivateReposOnly, it lists the private
+// repositories the token has access to, like the other providers.
+func (p *giteaProvider) UserRepos(ctx context.Context, user string) ([]Repo, error) {
+	if !*scanPrivateReposOnly {
+		return p.repos(ctx, "/users/"+url.PathEscape(user)+"/repos")
+	}
+
+	repos, err := p.repos(ctx, "/user/repos")
+	if err != nil {
+		return nil, err
+	}
+	var private []Repo
+	for _, r := range repos {
+		if r.Private {
+			private = append(private, r)
+		}
+	}
+	return private, nil
+}
+
+// UserGists returns nothing, as Gitea has no gists or snippets
+func (p *giteaProvider) UserGists(ctx context.Context, user string) ([]Repo, error) {
+	return nil, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGiteaOrgRepos(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/orgs/acme/repos" {
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// the instance returns fewer repos per page than the limit that was asked for
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `[{"name":"api","clone_url":"https://gitea.example.com/acme/api.git","private":true,"owner":{"login":"acme"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"name":"web","clone_url":"https://gitea.example.com/acme/web.git","fork":true,"owner":{"login":"acme"}}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+
+	repos, err := newGiteaProvider(srv.Client(), srv.URL, "secret").OrgRepos(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "api" || repos[1].Name != "web" {
+		t.Fatalf("got repos %+v, want api and web", repos)
+	}
+	if !repos[0].Private || !repos[1].Fork || repos[1].Owner != "acme" {
+		t.Errorf("private, fork or owner wasn't mapped: %+v", repos)
+	}
+
+	// listing stops at the first empty page
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+}
+
+func TestGiteaToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := newGiteaProvider(srv.Client(), srv.URL, "wrong").OrgMembers(context.Background(), "acme"); err == nil {
+		t.Error("listing with the wrong token succeeded")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// githubProvider lists repositories on github.com or a Github Enterprise Server instance
+type githubProvider struct {
+	client *github.Client
+}
+
+var errTeamNotFound = errors.New("team not found")
+
+// newGithubClient returns a client for api.github.com, or for the Github Enterprise Server
+// instance at baseURL if it isn't empty
+func newGithubClient(httpClient *http.Client, baseURL string, uploadURL string) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if baseURL == "" {
+		return client, nil
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if uploadURL == "" {
+		uploadURL = strings.TrimSuffix(baseURL, "/api/v3") + "/api/uploads/"
+	}
+	if !strings.HasSuffix(baseURL, "/api/v3") {
+		baseURL += "/api/v3"
+	}
+
+	var err error
+	if client.BaseURL, err = url.Parse(baseURL + "/"); err != nil {
+		return nil, err
+	}
+	if client.UploadURL, err = url.Parse(strings.TrimSuffix(uploadURL, "/") + "/"); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func githubRepo(r *github.Repository) Repo {
+	repo := Repo{
+		Name:     r.GetName(),
+		CloneURL: r.GetCloneURL(),
+		SSHURL:   r.GetSSHURL(),
+		Fork:     r.GetFork(),
+		Private:  r.GetPrivate(),
+	}
+	if r.Owner != nil {
+		repo.Owner = r.Owner.GetLogin()
+	}
+	if r.PushedAt != nil {
+		repo.PushedAt = r.PushedAt.Time
+	}
+	return repo
+}
+
+func githubRepos(repos []*github.Repository) []Repo {
+	var converted []Repo
+	for _, r := range repos {
+		converted = append(converted, githubRepo(r))
+	}
+	return converted
+}
+
+func (p *githubProvider) OrgRepos(ctx context.Context, org string) ([]Repo, error) {
+	var orgRepos []*github.Repository
+	opt := &github.RepositoryListByOrgOptions{
+		Type:        "all", // private and internal repositories are only listed with all or private
+		ListOptions: github.ListOptions{PerPage: 10},
+	}
+
+	for {
+		repos, resp, err := p.client.Repositories.ListByOrg(ctx, org, opt)
+		if err != nil {
+			return nil, err
+		}
+		orgRepos = append(orgRepos, repos...) //adding to the repo array
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return githubRepos(orgRepos), nil
+}
+
+func (p *githubProvider) OrgMembers(ctx context.Context, org string) ([]string, error) {
+	var allUsers []string
+	opt2 := &github.ListMembersOptions{
+		ListOptions: github.ListOptions{PerPage: 10},
+	}
+
+	for {
+		users, resp, err := p.client.Organizations.ListMembers(ctx, org, opt2)
+		if err != nil {
+			return allUsers, err
+		}
+		for _, u := range users {
+			allUsers = append(allUsers, u.GetLogin()) //adding to the allUsers array
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt2.Page = resp.NextPage
+	}
+
+	return allUsers, nil
+}
+
+func (p *githubProvider) UserRepos(ctx context.Context, user string) ([]Repo, error) {
+	var uname string
+	var userRepos []*github.Repository
+	var opt3 *github.RepositoryListOptions
+
+	if *scanPrivateReposOnly {
+		uname = ""
+		opt3 = &github.RepositoryListOptions{
+			Visibility:  "private",
+			ListOptions: github.ListOptions{PerPage: 10},
+		}
+	} else {
+		uname = user
+		opt3 = &github.RepositoryListOptions{
+			ListOptions: github.ListOptions{PerPage: 10},
+		}
+	}
+
+	for {
+		uRepos, resp, err := p.client.Repositories.List(ctx, uname, opt3)
+		if err != nil {
+			return nil, err
+		}
+		userRepos = append(userRepos, uRepos...) //adding to the userRepos array
+		if resp.NextPage == 0 {
+			break
+		}
+		opt3.Page = resp.NextPage
+	}
+
+	return githubRepos(userRepos), nil
+}
+
+func (p *githubProvider) UserGists(ctx context.Context, user string) ([]Repo, error) {
+	var uname2 string
+
+	if *scanPrivateReposOnly {
+		uname2 = ""
+	} else {
+		uname2 = user
+	}
+
+	var userGists []Repo
+	opt4 := &github.GistListOptions{
+		ListOptions: github.ListOptions{PerPage: 10},
+	}
+	for {
+		uGists, resp, err := p.client.Gists.List(ctx, uname2, opt4)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range uGists {
+			userGists = append(userGists, Repo{
+				Name:     g.GetID(),
+				Owner:    user,
+				CloneURL: g.GetGitPullURL(),
+				Private:  !g.GetPublic(),
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt4.Page = resp.NextPage
+	}
+
+	return userGists, nil
+}
+
+// UserTeams lists the names of the teams in org that the user the token belongs to is a member of
+func (p *githubProvider) UserTeams(ctx context.Context, org string) ([]string, error) {
+	var names []string
+	opt := &github.ListOptions{
+		PerPage: 10,
+	}
+
+	for {
+		teams, resp, err := p.client.Organizations.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			if team.Organization != nil && strings.EqualFold(team.Organization.GetLogin(), org) {
+				names = append(names, team.GetName())
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return names, nil
+}
+
+func (p *githubProvider) findTeamByName(ctx context.Context, org string, teamName string) (*github.Team, error) {
+
+	listTeamsOpts := &github.ListOptions{
+		PerPage: 10,
+	}
+	Info("Listing teams...")
+	for {
+		teams, resp, err := p.client.Organizations.ListTeams(ctx, org, listTeamsOpts)
+		if err != nil {
+			return nil, err
+		}
+		//check the name here--try to avoid additional API calls if we've found the team
+		for _, team := range teams {
+			if *team.Name == teamName {
+				return team, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listTeamsOpts.Page = resp.NextPage
+	}
+	return nil, errTeamNotFound
+}
+
+func (p *githubProvider) TeamRepos(ctx context.Context, org string, teamName string) ([]Repo, error) {
+	team, err := p.findTeamByName(ctx, org, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	Info("Cloning the repositories of the team: %s(%d)", *team.Name, *team.ID)
+	var teamRepos []*github.Repository
+	listTeamRepoOpts := &github.ListOptions{
+		PerPage: 10,
+	}
+
+	Info("Listing team repositories...")
+	for {
+		repos, resp, err := p.client.Organizations.ListTeamRepos(ctx, *team.ID, listTeamRepoOpts)
+		if err != nil {
+			return nil, err
+		}
+		teamRepos = append(teamRepos, repos...) //adding to the repo array
+		if resp.NextPage == 0 {
+			break
+		}
+		listTeamRepoOpts.Page = resp.NextPage
+	}
+
+	return githubRepos(teamRepos), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// githubAppTokenSource mints installation access tokens for a Github App. Installation tokens
+// expire after an hour, so it is wrapped in an oauth2.ReuseTokenSource that mints a new one
+// whenever the current one has expired.
+type githubAppTokenSource struct {
+	client         *http.Client
+	apiURL         string
+	appID          int64
+	key            *rsa.PrivateKey
+	installationID int64
+}
+
+// githubTokenSources returns the sources of the tokens to authenticate to Github with. Those are
+// the comma separated tokens of the -token flag, or the installation tokens of the Github App if
+// -githubAppID is set.
+func githubTokenSources(ctx context.Context) ([]oauth2.TokenSource, error) {
+	if *githubAppID == 0 {
+		var sources []oauth2.TokenSource
+		for _, t := range strings.Split(*token, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				sources = append(sources, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: t}))
+			}
+		}
+		if len(sources) == 0 {
+			return nil, errors.New("no token to authenticate to Github with")
+		}
+		return sources, nil
+	}
+
+	key, err := loadGithubAppKey(*githubAppKey)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &githubAppTokenSource{
+		client:         http.DefaultClient,
+		apiURL:         githubAPIURL(*githubURL),
+		appID:          *githubAppID,
+		key:            key,
+		installationID: *githubAppInstallID,
+	}
+	if src.installationID == 0 {
+		if src.installationID, err = src.findInstallation(ctx, *org, *user); err != nil {
+			return nil, err
+		}
+	}
+	return []oauth2.TokenSource{oauth2.ReuseTokenSource(nil, src)}, nil
+}
+
+// githubAPIURL returns the REST API URL of api.github.com, or of the Github Enterprise Server
+// instance at baseURL if it isn't empty
+func githubAPIURL(baseURL string) string {
+	if baseURL == "" {
+		return "https://api.github.com"
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return strings.TrimSuffix(baseURL, "/api/v3") + "/api/v3"
+}
+
+// loadGithubAppKey reads the PEM encoded private key of a Github App, as downloaded from its settings
+func loadGithubAppKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM encoded private key found", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA private key", path)
+	}
+	return key, nil
+}
+
+// jwt returns a JSON Web Token signed with RS256 to authenticate as the app itself. Github accepts
+// them for at most 10 minutes, and the issue time is set in the past to allow for clock drift.
+func (s *githubAppTokenSource) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *githubAppTokenSource) headers() (map[string]string, error) {
+	jwt, err := s.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"Authorization": "Bearer " + jwt,
+		"Accept":        "application/vnd.github.v3+json",
+	}, nil
+}
+
+// findInstallation looks up the installation of the app on the org or user to scan
+func (s *githubAppTokenSource) findInstallation(ctx context.Context, org string, user string) (int64, error) {
+	var path string
+	switch {
+	case org != "":
+		path = "/orgs/" + org + "/installation"
+	case user != "":
+		path = "/users/" + user + "/installation"
+	default:
+		return 0, errors.New("githubAppInstallation is needed when neither an org nor a user is scanned")
+	}
+
+	headers, err := s.headers()
+	if err != nil {
+		return 0, err
+	}
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if _, err := getJSON(ctx, s.client, s.apiURL+path, headers, &installation); err != nil {
+		return 0, err
+	}
+	return installation.ID, nil
+}
+
+// Token mints a new installation access token
+func (s *githubAppTokenSource) Token() (*oauth2.Token, error) {
+	headers, err := s.headers()
+	if err != nil {
+		return nil, err
+	}
+
+	url := s.apiURL + "/app/installations/" + strconv.FormatInt(s.installationID, 10) + "/access_tokens"
+	req, err := http.NewRequest("POST", url, bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+
+	var installationToken struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&installationToken); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: installationToken.Token, Expiry: installationToken.ExpiresAt}, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitlabProvider lists projects and snippets on gitlab.com or a self-hosted Gitlab instance.
+// Groups, including their nested subgroups, take the place of Github organizations.
+type gitlabProvider struct {
+	client  *http.Client
+	baseURL string
+	token   string
+
+	ownerOnce sync.Once
+	owner     string
+	ownerErr  error
+}
+
+type gitlabProject struct {
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	HTTPURLToRepo     string    `json:"http_url_to_repo"`
+	SSHURLToRepo      string    `json:"ssh_url_to_repo"`
+	Visibility        string    `json:"visibility"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+}
+
+type gitlabSnippet struct {
+	ID            int    `json:"id"`
+	Visibility    string `json:"visibility"`
+	HTTPURLToRepo string `json:"http_url_to_repo"`
+	SSHURLToRepo  string `json:"ssh_url_to_repo"`
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+// newGitlabProvider returns a provider for the Gitlab instance at baseURL, e.g. https://gitlab.example.com
+func newGitlabProvider(client *http.Client, baseURL string, token string) *gitlabProvider {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, "/api/v4") {
+		baseURL += "/api/v4"
+	}
+	return &gitlabProvider{client: client, baseURL: baseURL, token: token}
+}
+
+// headers returns the authentication headers
+func (p *gitlabProvider) headers() map[string]string {
+	if p.token == "" {
+		return nil
+	}
+	return map[string]string{"PRIVATE-TOKEN": p.token}
+}
+
+// list requests every page of the Gitlab API endpoint at path and calls add with each page
+func (p *gitlabProvider) list(ctx context.Context, path string, query url.Values, page func() interface{}, add func(v interface{})) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "100")
+	query.Set("page", "1")
+
+	for {
+		v := page()
+		header, err := getJSON(ctx, p.client, p.baseURL+path+"?"+query.Encode(), p.headers(), v)
+		if err != nil {
+			return err
+		}
+		add(v)
+
+		next := header.Get("X-Next-Page")
+		if next == "" {
+			return nil
+		}
+		query.Set("page", next)
+	}
+}
+
+// projects lists the projects at path. name is used to turn each project into the name of the
+// directory it is cloned to.
+func (p *gitlabProvider) projects(ctx context.Context, path string, query url.Values, owner string, name func(gitlabProject) string) ([]Repo, error) {
+	var repos []Repo
+	err := p.list(ctx, path, query, func() interface{} { return &[]gitlabProject{} }, func(v interface{}) {
+		for _, project := range *v.(*[]gitlabProject) {
+			repos = append(repos, Repo{
+				Name:     name(project),
+				Owner:    owner,
+				CloneURL: project.HTTPURLToRepo,
+				SSHURL:   project.SSHURLToRepo,
+				Fork:     project.ForkedFromProject != nil,
+				Private:  project.Visibility != "public",
+				PushedAt: project.LastActivityAt,
+			})
+		}
+	})
+	return repos, err
+}
+
+// OrgRepos lists the projects of a group and all of its subgroups. Projects in subgroups are
+// named after their path in the group with "/" replaced by "_", so that projects with the same
+// name in different subgroups don't overwrite each other.
+func (p *gitlabProvider) OrgRepos(ctx context.Context, org string) ([]Repo, error) {
+	query := url.Values{"include_subgroups": {"true"}}
+	return p.projects(ctx, "/groups/"+url.PathEscape(org)+"/projects", query, org, func(project gitlabProject) string {
+		return strings.Replace(strings.TrimPrefix(project.PathWithNamespace, org+"/"), "/", "_", -1)
+	})
+}
+
+// OrgMembers lists the members of a group, including the ones inherited from parent groups
+func (p *gitlabProvider) OrgMembers(ctx context.Context, org string) ([]string, error) {
+	var members []string
+	err := p.list(ctx, "/groups/"+url.PathEscape(org)+"/members/all", nil, func() interface{} { return &[]gitlabUser{} }, func(v interface{}) {
+		for _, u := range *v.(*[]gitlabUser) {
+			members = append(members, u.Username)
+		}
+	})
+	return members, err
+}
+
+func (p *gitlabProvider) UserRepos(ctx context.Context, user string) ([]Repo, error) {
+	name := func(project gitlabProject) string { return project.Path }
+
+	if *scanPrivateReposOnly {
+		query := url.Values{"owned": {"true"}, "visibility": {"private"}}
+		return p.projects(ctx, "/projects", query, user, name)
+	}
+	return p.projects(ctx, "/users/"+url.PathEscape(user)+"/projects", nil, user, name)
+}
+
+// tokenOwner returns the username of the user the token belongs to
+func (p *gitlabProvider) tokenOwner(ctx context.Context) (string, error) {
+	p.ownerOnce.Do(func() {
+		var u gitlabUser
+		_, p.ownerErr = getJSON(ctx, p.client, p.baseURL+"/user", p.headers(), &u)
+		p.owner = u.Username
+	})
+	return p.owner, p.ownerErr
+}
+
+// UserGists lists the personal snippets of user. Gitlab can only list the snippets of the user the
+// token belongs to, so nothing is listed for other users.
+func (p *gitlabProvider) UserGists(ctx context.Context, user string) ([]Repo, error) {
+	if p.token == "" {
+		return nil, nil
+	}
+	owner, err := p.tokenOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(owner, user) {
+		return nil, nil
+	}
+
+	var snippets []Repo
+	err = p.list(ctx, "/snippets", nil, func() interface{} { return &[]gitlabSnippet{} }, func(v interface{}) {
+		for _, snippet := range *v.(*[]gitlabSnippet) {
+			if *scanPrivateReposOnly && snippet.Visibility == "public" {
+				continue
+			}
+			snippets = append(snippets, Repo{
+				Name:     strconv.Itoa(snippet.ID),
+				Owner:    user,
+				CloneURL: snippet.HTTPURLToRepo,
+				SSHURL:   snippet.SSHURLToRepo,
+				Private:  snippet.Visibility != "public",
+			})
+		}
+	})
+	return snippets, err
+}
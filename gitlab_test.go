@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeGitlab returns a fake Gitlab API that only answers requests made with the token "secret"
+func newFakeGitlab(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v4/groups/top/projects":
+			if r.URL.Query().Get("include_subgroups") != "true" {
+				t.Errorf("subgroups weren't included: %s", r.URL.RawQuery)
+			}
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"path":"app","path_with_namespace":"top/app","http_url_to_repo":"https://gitlab.example.com/top/app.git","visibility":"public"}]`)
+				return
+			}
+			fmt.Fprint(w, `[{"path":"app","path_with_namespace":"top/team/app","http_url_to_repo":"https://gitlab.example.com/top/team/app.git","visibility":"private","forked_from_project":{"id":1}}]`)
+		case "/api/v4/groups/top/members/all":
+			fmt.Fprint(w, `[{"username":"alice"},{"username":"bob"}]`)
+		case "/api/v4/user":
+			fmt.Fprint(w, `{"username":"alice"}`)
+		case "/api/v4/snippets":
+			fmt.Fprint(w, `[{"id":7,"visibility":"private","http_url_to_repo":"https://gitlab.example.com/snippets/7.git"}]`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGitlabOrgRepos(t *testing.T) {
+	srv := newFakeGitlab(t)
+	defer srv.Close()
+
+	repos, err := newGitlabProvider(srv.Client(), srv.URL, "secret").OrgRepos(context.Background(), "top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("got %d repos, want 2 from both pages", len(repos))
+	}
+
+	// projects in subgroups are named after their path in the group
+	if repos[0].Name != "app" || repos[1].Name != "team_app" {
+		t.Errorf("got names %q and %q, want app and team_app", repos[0].Name, repos[1].Name)
+	}
+	if repos[0].Private || !repos[1].Private || !repos[1].Fork {
+		t.Errorf("visibility or fork wasn't mapped: %+v", repos)
+	}
+}
+
+func TestGitlabOrgMembers(t *testing.T) {
+	srv := newFakeGitlab(t)
+	defer srv.Close()
+
+	members, err := newGitlabProvider(srv.Client(), srv.URL, "secret").OrgMembers(context.Background(), "top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0] != "alice" || members[1] != "bob" {
+		t.Errorf("got members %v, want [alice bob]", members)
+	}
+}
+
+func TestGitlabUserGists(t *testing.T) {
+	srv := newFakeGitlab(t)
+	defer srv.Close()
+
+	p := newGitlabProvider(srv.Client(), srv.URL, "secret")
+	snippets, err := p.UserGists(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 || snippets[0].Name != "7" {
+		t.Errorf("got snippets %+v, want snippet 7 of the token owner", snippets)
+	}
+
+	// the snippets of other users can't be listed
+	snippets, err = p.UserGists(context.Background(), "bob")
+	if err != nil || len(snippets) != 0 {
+		t.Errorf("got snippets %+v and error %v for another user, want none", snippets, err)
+	}
+}
+
+func TestGitlabToken(t *testing.T) {
+	srv := newFakeGitlab(t)
+	defer srv.Close()
+
+	if _, err := newGitlabProvider(srv.Client(), srv.URL, "wrong").OrgRepos(context.Background(), "top"); err == nil {
+		t.Error("listing with the wrong token succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type gitsecretsScanner struct{}
+
+func init() {
+	registerScanner(gitsecretsScanner{})
+}
+
+func (gitsecretsScanner) Name() string { return "gitsecrets" }
+
+// Scan ignores commits since git secrets only scans the files that are checked out
+func (gitsecretsScanner) Scan(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error) {
+	outputFile := newResultPath("gitsecrets", reponame, orgoruser)
+	// git secrets --scan exits with 1 when it matches a prohibited pattern
+	if err := runGitsecrets(filepath, outputFile); err != nil && !foundIssues(err, outputFile) {
+		return nil, err
+	}
+	return parseResultFile(outputFile, func(r io.Reader) ([]Finding, error) {
+		return parseGitsecrets(r, reponame, orgoruser)
+	})
+}
+
+func runGitsecrets(filepath string, outputFile2 string) error {
+	cmd2 := exec.Command("./rungitsecrets.sh", filepath, outputFile2)
+	var out2 bytes.Buffer
+	cmd2.Stdout = &out2
+	return cmd2.Run()
+}
+
+// gitsecretsLine matches the "path:line:match" lines git secrets --scan prints for every match
+var gitsecretsLine = regexp.MustCompile(`^(.+?):(\d+):(.*)$`)
+
+// gitsecretsPatterns are the prohibited patterns rungitsecrets.sh registers. git secrets prints the
+// whole line a pattern matched in, so they are applied to the line again to find the secret itself,
+// which is the "secret" group if there is one and the whole match otherwise. That way, the same
+// secret found by another tool has the same fingerprint.
+var gitsecretsPatterns = []regexRule{
+	{"AWS API Key", regexp.MustCompile(`(?:A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z0-9]{16}`)},
+	{"AWS Secret Key", regexp.MustCompile(`["']?(?:AWS|aws|Aws)?_?(?:SECRET|secret|Secret)?_?(?:ACCESS|access|Access)?_?(?:KEY|key|Key)["']?\s*(?::|=>|=)\s*["']?(?P<secret>[A-Za-z0-9/\+=]{40})["']?`)},
+	{"AWS Account ID", regexp.MustCompile(`["']?(?:AWS|aws|Aws)?_?(?:ACCOUNT|account|Account)_?(?:ID|id|Id)?["']?\s*(?::|=>|=)\s*["']?(?P<secret>[0-9]{4}-?[0-9]{4}-?[0-9]{4})["']?`)},
+	{"Slack Token", regexp.MustCompile(`xox[pb]-.*`)},
+}
+
+// gitsecretsMatches returns the secrets in a line printed by git secrets, with the name of the
+// pattern that matched each. A line that none of gitsecretsPatterns match, e.g. because of a pattern
+// added to the git config outside of rungitsecrets.sh, is returned whole.
+func gitsecretsMatches(line string) (rules []string, secrets []string) {
+	for _, p := range gitsecretsPatterns {
+		group := p.re.SubexpIndex("secret")
+		for _, m := range p.re.FindAllStringSubmatch(line, -1) {
+			secret := m[0]
+			if group != -1 {
+				secret = m[group]
+			}
+			rules = append(rules, p.name)
+			secrets = append(secrets, strings.TrimSpace(secret))
+		}
+	}
+	if len(secrets) == 0 {
+		return []string{"Prohibited pattern"}, []string{strings.TrimSpace(line)}
+	}
+	return rules, secrets
+}
+
+// parseGitsecrets turns the output of git secrets --scan into findings
+func parseGitsecrets(r io.Reader, reponame string, orgoruser string) ([]Finding, error) {
+	var findings []Finding
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		m := gitsecretsLine.FindStringSubmatch(stripANSI(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		lineno, _ := strconv.Atoi(m[2])
+
+		rules, secrets := gitsecretsMatches(m[3])
+		for i, secret := range secrets {
+			findings = append(findings, Finding{
+				Tool:      "gitsecrets",
+				Rule:      rules[i],
+				OrgOrUser: orgoruser,
+				Repo:      reponame,
+				File:      strings.TrimPrefix(m[1], "./"),
+				Line:      lineno,
+				Secret:    secret,
+				Preview:   redact(secret),
+			})
+		}
+	}
+	return findings, scanner.Err()
+}
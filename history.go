@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyLine is a line added to a file by a commit. Lines read from a directory that
+// is not a git repository have an empty Commit, Author and Date.
+type historyLine struct {
+	Commit string
+	Author string
+	Date   time.Time
+	Path   string
+	Line   int
+	Text   string
+}
+
+// scanLines calls fn for every line added by every commit in commits on every branch of the repository
+// at dir. If dir isn't a git repository, fn is called for every line of every file in it instead.
+func scanLines(dir string, commits commitRange, fn func(l historyLine)) error {
+	repo, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		return scanFileLines(dir, func(path string, lineno int, line string) {
+			fn(historyLine{Path: path, Line: lineno, Text: line})
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	var since []plumbing.Hash
+	for _, h := range commits.Since {
+		since = append(since, plumbing.NewHash(h))
+	}
+	return walkHistory(repo, since, fn)
+}
+
+// reachable returns every commit reachable from tips. Tips that aren't in repo anymore, e.g.
+// because a branch was force pushed, are ignored.
+func reachable(repo *git.Repository, tips []plumbing.Hash) map[plumbing.Hash]bool {
+	seen := make(map[plumbing.Hash]bool)
+	queue := append([]plumbing.Hash(nil), tips...)
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if seen[h] {
+			continue
+		}
+		c, err := repo.CommitObject(h)
+		if err != nil {
+			continue
+		}
+		seen[h] = true
+		queue = append(queue, c.ParentHashes...)
+	}
+	return seen
+}
+
+// parentLines returns the set of lines of the file at path in any of trees
+func parentLines(trees []*object.Tree, path string) map[string]bool {
+	lines := make(map[string]bool)
+	for _, tree := range trees {
+		f, err := tree.File(path)
+		if err != nil {
+			continue
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(contents, "\n") {
+			lines[strings.TrimSuffix(line, "\r")] = true
+		}
+	}
+	return lines
+}
+
+// walkHistory goes through the diff of every commit reachable from any ref in repo and calls fn
+// for each added line. Merge commits are diffed against their first parent, and only the lines that
+// are in none of the other parents are reported, so that the changes of the merged commits aren't
+// attributed to the merge again but conflict resolutions and other edits made in the merge are.
+// Commits reachable from since were scanned before and are skipped.
+func walkHistory(repo *git.Repository, since []plumbing.Hash, fn func(l historyLine)) error {
+	scanned := reachable(repo, since)
+
+	commits, err := repo.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return err
+	}
+	defer commits.Close()
+
+	return commits.ForEach(func(c *object.Commit) error {
+		if scanned[c.Hash] {
+			return nil
+		}
+
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		var parents []*object.Tree
+		err = c.Parents().ForEach(func(parent *object.Commit) error {
+			parentTree, err := parent.Tree()
+			if err != nil {
+				return err
+			}
+			parents = append(parents, parentTree)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		var parentTree *object.Tree
+		if len(parents) > 0 {
+			parentTree = parents[0]
+		}
+
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+		patch, err := changes.Patch()
+		if err != nil {
+			return err
+		}
+
+		for _, fp := range patch.FilePatches() {
+			_, to := fp.Files()
+			if fp.IsBinary() || to == nil {
+				continue
+			}
+
+			var merged map[string]bool
+			if len(parents) > 1 {
+				merged = parentLines(parents[1:], to.Path())
+			}
+
+			lineno := 1
+			for _, chunk := range fp.Chunks() {
+				lines := strings.SplitAfter(chunk.Content(), "\n")
+				if lines[len(lines)-1] == "" {
+					lines = lines[:len(lines)-1]
+				}
+
+				switch chunk.Type() {
+				case diff.Equal:
+					lineno += len(lines)
+				case diff.Add:
+					for _, line := range lines {
+						text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+						if !merged[text] {
+							fn(historyLine{
+								Commit: c.Hash.String(),
+								Author: c.Author.Name + " <" + c.Author.Email + ">",
+								Date:   c.Author.When,
+								Path:   to.Path(),
+								Line:   lineno,
+								Text:   text,
+							})
+						}
+						lineno++
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyRepo builds a repository in which a side branch and the main branch both change the same
+// line, and the merge resolves the conflict with a line of its own:
+//
+//	base -- trunk ----- merge
+//	    \              /
+//	     side --------
+func historyRepo(t *testing.T) (repo *git.Repository, base, side, trunk, merge plumbing.Hash) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(files map[string]string, remove []string, parents ...plumbing.Hash) plumbing.Hash {
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range remove {
+			if _, err := wt.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		when = when.Add(time.Hour)
+		h, err := wt.Commit("commit", &git.CommitOptions{
+			Author:  &object.Signature{Name: "dev", Email: "dev@example.com", When: when},
+			Parents: parents,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	base = commit(map[string]string{"a.txt": "x\ny\n"}, nil)
+	side = commit(map[string]string{"a.txt": "side\ny\n", "s.txt": "s\n"}, nil, base)
+	trunk = commit(map[string]string{"a.txt": "main\ny\n"}, []string{"s.txt"}, base)
+	merge = commit(map[string]string{"a.txt": "resolved\ny\n", "s.txt": "s\n"}, nil, trunk, side)
+	return repo, base, side, trunk, merge
+}
+
+func TestWalkHistory(t *testing.T) {
+	repo, base, side, trunk, merge := historyRepo(t)
+
+	tests := []struct {
+		name  string
+		since []plumbing.Hash
+		want  []historyLine
+	}{
+		{
+			name: "whole history",
+			want: []historyLine{
+				{Commit: merge.String(), Path: "a.txt", Line: 1, Text: "resolved"},
+				{Commit: trunk.String(), Path: "a.txt", Line: 1, Text: "main"},
+				{Commit: side.String(), Path: "a.txt", Line: 1, Text: "side"},
+				{Commit: side.String(), Path: "s.txt", Line: 1, Text: "s"},
+				{Commit: base.String(), Path: "a.txt", Line: 1, Text: "x"},
+				{Commit: base.String(), Path: "a.txt", Line: 2, Text: "y"},
+			},
+		},
+		{
+			name:  "since the side branch",
+			since: []plumbing.Hash{side},
+			want: []historyLine{
+				{Commit: merge.String(), Path: "a.txt", Line: 1, Text: "resolved"},
+				{Commit: trunk.String(), Path: "a.txt", Line: 1, Text: "main"},
+			},
+		},
+		{
+			name:  "since the merge",
+			since: []plumbing.Hash{merge},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[historyLine]bool)
+			err := walkHistory(repo, tt.since, func(l historyLine) {
+				got[historyLine{Commit: l.Commit, Path: l.Path, Line: l.Line, Text: l.Text}] = true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, l := range tt.want {
+				if !got[l] {
+					t.Errorf("line %+v wasn't reported", l)
+				}
+				delete(got, l)
+			}
+			for l := range got {
+				t.Errorf("unexpected line %+v", l)
+			}
+		})
+	}
+}
@@ -1,41 +1,72 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"sync"
 
-	"golang.org/x/oauth2"
+	gossh "golang.org/x/crypto/ssh"
 
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/github"
-	uuid "github.com/satori/go.uuid"
 )
 
 var (
 	org                  = flag.String("org", "", "Name of the Organization to scan. Example: secretorg123")
-	token                = flag.String("token", "", "Github Personal Access Token. This is required.")
+	token                = flag.String("token", "", "Github Personal Access Token. This is required. Several comma separated tokens are rotated through on Github")
 	outputFile           = flag.String("output", "results.txt", "Output file to save the results.")
+	format               = flag.String("format", "text", "Format of the output file. Either text, json, ndjson or sarif. Default is text")
+	baselineFile         = flag.String("baseline", "", "JSON results of a previous run. Findings already in it are not reported again")
+	updateBaseline       = flag.Bool("updateBaseline", false, "Option to save the findings of this run to the baseline file. Default is false")
+	showKnown            = flag.Bool("showKnown", false, "Option to report findings that are in the baseline, marked as known. Default is false")
+	errorPolicy          = flag.String("errorPolicy", "never", "When errors during the run should lead to a non-zero exit code. Either never, scan or any. Default is never")
 	user                 = flag.String("user", "", "Name of the Github user to scan. Example: secretuser1")
 	repoURL              = flag.String("repoURL", "", "HTTPS URL of the Github repo to scan. Example: https://github.com/anshumantestorg/repo1.git")
+	localPath            = flag.String("path", "", "Local directory to scan instead of cloning from a provider. Either a repository or a directory of repositories. No token is needed")
+	targetsFile          = flag.String("targets", "", "File with one git URL to clone and scan per line. Any host and any of https, ssh, git and file URLs can be used")
 	gistURL              = flag.String("gistURL", "", "HTTPS URL of the Github gist to scan. Example: https://gist.github.com/secretuser1/81963f276280d484767f9be895316afc")
 	cloneForks           = flag.Bool("cloneForks", false, "Option to clone org and user repos that are forks. Default is false")
 	orgOnly              = flag.Bool("orgOnly", false, "Option to skip cloning user repo's when scanning an org. Default is false")
-	toolName             = flag.String("toolName", "all", "Specify whether to run gitsecrets, thog or repo-supervisor")
+	toolName             = flag.String("toolName", "all", "Comma separated list of tools to run. Example: gitsecrets,thog. Default is all")
 	teamName             = flag.String("teamName", "", "Name of the Organization Team which has access to private repositories for scanning.")
 	scanPrivateReposOnly = flag.Bool("scanPrivateReposOnly", false, "Option to scan private repositories only. Default is false")
 	threads              = flag.Int("threads", 10, "Amount of parallel threads")
+	cloneThreads         = flag.Int("cloneThreads", 5, "Amount of repositories cloned in parallel")
+	workdir              = flag.String("workdir", os.TempDir(), "Directory to store the clones and raw tool results in. Every run gets its own subdirectory. Default is the system temp directory")
+	cacheDir             = flag.String("cacheDir", "", "Directory to keep bare mirrors of the scanned repositories in between runs, so that later runs only fetch what changed")
+	stateFile            = flag.String("stateFile", "", "File to remember the scanned commits of every repository in, so that the next run only scans new commits")
+	fullScan             = flag.Bool("full", false, "Option to scan the whole history of every repository even if the stateFile has older scans. Default is false")
+	keepClones           = flag.Bool("keepClones", false, "Option to keep the cloned repositories after they are scanned. Default is false")
+	skipHostKeyCheck     = flag.Bool("skipHostKeyCheck", false, "Option to clone over SSH without checking the host key against known_hosts. Default is false")
+	keepRawResults       = flag.Bool("keepRawResults", false, "Option to keep the raw output of the external tools after the run. Default is false")
+	maxDiskMB            = flag.Int64("maxDiskMB", 0, "Disk space in MB that cloned repositories waiting to be scanned can take before cloning is paused. Default is no limit")
+	providerName         = flag.String("provider", "github", "Code hosting service to scan. Either github, gitlab, bitbucket or gitea. Default is github")
+	gitlabURL            = flag.String("gitlabURL", "https://gitlab.com", "Base URL of the Gitlab instance to scan when the provider is gitlab")
+	bitbucketURL         = flag.String("bitbucketURL", "https://api.bitbucket.org", "Base URL of the Bitbucket Server instance to scan when the provider is bitbucket. Default is Bitbucket Cloud")
+	giteaURL             = flag.String("giteaURL", "", "Base URL of the Gitea or Forgejo instance to scan when the provider is gitea. Example: https://gitea.example.com")
+	githubURL            = flag.String("githubURL", "", "Base URL of a Github Enterprise Server instance. Example: https://github.example.com")
+	githubAppID          = flag.Int64("githubAppID", 0, "ID of the Github App to authenticate as instead of using a token")
+	githubAppKey         = flag.String("githubAppKey", "", "Private key file of the Github App. Example: /root/app.private-key.pem")
+	githubAppInstallID   = flag.Int64("githubAppInstallation", 0, "Installation ID of the Github App. Defaults to the installation on the org or user to scan")
+	githubUploadURL      = flag.String("githubUploadURL", "", "Upload URL of a Github Enterprise Server instance. Defaults to <githubURL>/api/uploads/")
+	entropyB64Threshold  = flag.Float64("entropyB64Threshold", 4.5, "Shannon entropy above which a base64 string is reported by the entropy tool")
+	entropyHexThreshold  = flag.Float64("entropyHexThreshold", 3.0, "Shannon entropy above which a hex string is reported by the entropy tool")
+	entropyMinLength     = flag.Int("entropyMinLength", 20, "Minimum length of a string to be checked by the entropy tool")
 )
 
 var executionQueue chan bool
+
+// activeScanners holds the scanners selected with -toolName
+var activeScanners []Scanner
+
 func enqueueJob(item func()) {
 	executionQueue <- true
 	go func() {
@@ -49,275 +80,224 @@ func Info(format string, args ...interface{}) {
 	fmt.Printf("\x1b[34;1m%s\x1b[0m\n", fmt.Sprintf(format, args...))
 }
 
+// check stops the run on errors that leave nothing to scan or report, like failing to create the
+// output file. Errors for a single repository or tool are collected with results.addError instead.
 func check(e error) {
-	if e != nil {
-		panic(e)
-	} else if _, ok := e.(*github.RateLimitError); ok {
+	if e == nil {
+		return
+	}
+	if _, ok := e.(*github.RateLimitError); ok {
 		log.Println("hit rate limit")
 	} else if _, ok := e.(*github.AcceptedError); ok {
 		log.Println("scheduled on GitHub side")
 	}
+	log.Fatal(e)
 }
 
-func gitclone(cloneURL string, repoName string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// sshKeyPath is where the SSH key to clone private repositories with is mounted
+const sshKeyPath = "/root/.ssh/id_rsa"
 
-	cmd := exec.Command("/usr/bin/git", "clone", cloneURL, repoName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	check(err)
-}
+// knownHostsPath is where the host keys that SSH clones are checked against are read from
+const knownHostsPath = "/root/.ssh/known_hosts"
 
-// Moving cloning logic out of individual functions
-func executeclone(repo *github.Repository, directory string, wg *sync.WaitGroup) {
-	urlToClone := ""
-	switch *scanPrivateReposOnly {
-	case false:
-		urlToClone = *repo.CloneURL
-	case true:
-		urlToClone = *repo.SSHURL
-	default:
-		urlToClone = *repo.CloneURL
-	}
-
-	var orgclone sync.WaitGroup
-	// do not clone forks
-	if !*cloneForks && *repo.Fork {
-		fmt.Println(*repo.Name + " is a fork and the cloneFork flag was set to false so moving on..")
-	} else {
-		// clone it
-		orgclone.Add(1)
-		fmt.Println(urlToClone)
-		// thread out the git clone
-		func(orgclone *sync.WaitGroup, urlToClone string, directory string) {
-			enqueueJob(func() {
-				gitclone(urlToClone, directory, orgclone)
-			})
-		}(&orgclone, urlToClone, directory)
-	}
-
-	orgclone.Wait()
-	wg.Done()
+// hasSSHKey reports whether an SSH key is mounted at sshKeyPath
+func hasSSHKey() bool {
+	fi, err := os.Stat(sshKeyPath)
+	return err == nil && fi.Size() > 0
 }
 
-func cloneorgrepos(ctx context.Context, client *github.Client, org string) error {
-
-	Info("Cloning the repositories of the organization: " + org)
-	var orgRepos []*github.Repository
-	opt := &github.RepositoryListByOrgOptions{
-		ListOptions: github.ListOptions{PerPage: 10},
-	}
+// isSSHURL reports whether cloneURL is an SSH URL, either ssh:// or scp like user@host:path
+func isSSHURL(cloneURL string) bool {
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	return err == nil && endpoint.Protocol == "ssh"
+}
 
-	for {
-		repos, resp, err := client.Repositories.ListByOrg(ctx, org, opt)
-		check(err)
-		orgRepos = append(orgRepos, repos...) //adding to the repo array
-		if resp.NextPage == 0 {
-			break
-		}
-		opt.Page = resp.NextPage
+// repositoryURI turns cloneURL into an absolute URI to report findings with. scp like SSH URLs and
+// local paths become ssh:// and file:// URIs, and any credentials in the URL are removed.
+func repositoryURI(cloneURL string) string {
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	if err != nil {
+		return cloneURL
 	}
-
-	var orgrepowg sync.WaitGroup
-
-	//iterating through the repo array
-	for _, repo := range orgRepos {
-		orgrepowg.Add(1)
-		go executeclone(repo, "/tmp/repos/org/" + *repo.Name, &orgrepowg)
+	endpoint.Password = ""
+	if endpoint.Protocol != "ssh" {
+		endpoint.User = ""
 	}
-
-	orgrepowg.Wait()
-	fmt.Println("Done cloning org repos.")
-	return nil
+	return endpoint.String()
 }
 
-func cloneuserrepos(ctx context.Context, client *github.Client, user string) error {
-	Info("Cloning " + user + "'s repositories")
-
-	var uname string
-	var userRepos []*github.Repository
-	var opt3 *github.RepositoryListOptions
+// cloneAuth returns the credentials to clone cloneURL with. SSH URLs use the key mounted at
+// sshKeyPath, and the host key is checked against knownHostsPath unless -skipHostKeyCheck is set.
+// Without that file, go-git checks it against the default known_hosts files. HTTPS URLs on the provider's host use the token, which is handed to go-git
+// directly so that it never shows up in the URL, the clone's config or any process arguments.
+// Everything else is cloned anonymously.
+func cloneAuth(cloneURL string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	if err != nil {
+		return nil, err
+	}
 
-	if *scanPrivateReposOnly {
-		uname = ""
-		opt3 = &github.RepositoryListOptions{
-			Visibility:  "private",
-			ListOptions: github.ListOptions{PerPage: 10},
+	switch endpoint.Protocol {
+	case "ssh":
+		sshUser := endpoint.User
+		if sshUser == "" {
+			sshUser = "git"
 		}
-	} else {
-		uname = user
-		opt3 = &github.RepositoryListOptions{
-			ListOptions: github.ListOptions{PerPage: 10},
+		auth, err := ssh.NewPublicKeysFromFile(sshUser, sshKeyPath, "")
+		if err != nil {
+			return nil, err
+		}
+		if *skipHostKeyCheck {
+			auth.HostKeyCallback = gossh.InsecureIgnoreHostKey()
+		} else if _, err := os.Stat(knownHostsPath); err == nil {
+			if auth.HostKeyCallback, err = ssh.NewKnownHostsCallback(knownHostsPath); err != nil {
+				return nil, err
+			}
 		}
+		return auth, nil
+	case "http", "https":
+		if !sendsToken(*providerName, endpoint.Host) {
+			return nil, nil
+		}
+		token, err := providerToken()
+		if err != nil {
+			return nil, err
+		}
+		return cloneTokenAuth(*providerName, token, endpoint.Host), nil
 	}
+	return nil, nil
+}
 
-	for {
-		uRepos, resp, err := client.Repositories.List(ctx, uname, opt3)
-		check(err)
-		userRepos = append(userRepos, uRepos...) //adding to the userRepos array
-		if resp.NextPage == 0 {
-			break
+// gitclone clones cloneURL into the repoName directory. A failed clone is removed so that it isn't scanned.
+// With -cacheDir, the mirror of the repository in the cache is brought up to date first and the
+// working tree is cloned from it, so that only the new objects are fetched from the remote.
+func gitclone(cloneURL string, repoName string) error {
+	if *cacheDir != "" {
+		mirror, err := updateMirror(*cacheDir, cloneURL)
+		if err != nil {
+			return err
 		}
-		opt3.Page = resp.NextPage
+		cloneURL = mirror
 	}
 
-	var userrepowg sync.WaitGroup
-	//iterating through the userRepos array
-	for _, userRepo := range userRepos {
-		userrepowg.Add(1)
-		go executeclone(userRepo, "/tmp/repos/users/"+user+"/"+*userRepo.Name, &userrepowg)
+	auth, err := cloneAuth(cloneURL)
+	if err != nil {
+		return err
 	}
 
-	userrepowg.Wait()
-	fmt.Println("Done cloning user repos.")
-	return nil
-}
-
-func cloneusergists(ctx context.Context, client *github.Client, user string) error {
-	Info("Cloning " + user + "'s gists")
-
-	var uname2 string
-
-	if *scanPrivateReposOnly {
-		uname2 = ""
-	} else {
-		uname2 = user
+	_, err = git.PlainClone(repoName, false, &git.CloneOptions{
+		URL:  cloneURL,
+		Auth: auth,
+	})
+	if err != nil && err != git.ErrRepositoryAlreadyExists {
+		os.RemoveAll(repoName)
 	}
+	return err
+}
 
-	var userGists []*github.Gist
-	opt4 := &github.GistListOptions{
-		ListOptions: github.ListOptions{PerPage: 10},
+// enqueueClone adds repo to the pipeline, to be cloned into directory and scanned
+func enqueueClone(p *pipeline, repo Repo, directory string, orgoruser string) {
+	// private repos are cloned over SSH when a key is mounted, and over HTTPS with the token otherwise
+	urlToClone := repo.CloneURL
+	if *scanPrivateReposOnly && hasSSHKey() {
+		urlToClone = repo.SSHURL
 	}
-	for {
-		uGists, resp, err := client.Gists.List(ctx, uname2, opt4)
-		check(err)
-		userGists = append(userGists, uGists...)
-		if resp.NextPage == 0 {
-			break
-		}
-		opt4.Page = resp.NextPage
+	if urlToClone == "" {
+		urlToClone = repo.CloneURL
 	}
 
-	var usergistclone sync.WaitGroup
-	//iterating through the userGists array
-	for _, userGist := range userGists {
-		usergistclone.Add(1)
-		fmt.Println(*userGist.GitPullURL)
+	// do not clone forks
+	if !*cloneForks && repo.Fork {
+		fmt.Println(repo.Name + " is a fork and the cloneFork flag was set to false so moving on..")
+		return
+	}
 
-		//cloning the individual user gists
-		func (userGist *github.Gist, user string, usergistclone *sync.WaitGroup) {
-			enqueueJob(func() {
-				gitclone(*userGist.GitPullURL, "/tmp/repos/users/" + user + "/" + *userGist.ID, usergistclone)
-			})
-		}(userGist, user, &usergistclone)
+	// do not clone repos that weren't pushed to since the last scan
+	if unchanged(urlToClone, repo.PushedAt) {
+		fmt.Println(repo.Name + " is unchanged since it was last scanned so moving on..")
+		return
 	}
 
-	usergistclone.Wait()
-	return nil
+	p.add(cloneJob{url: urlToClone, dir: directory, name: repo.Name, orgoruser: orgoruser, pushedAt: repo.PushedAt})
 }
 
-func listallusers(ctx context.Context, client *github.Client, org string) ([]*github.User, error) {
-	Info("Listing users of the organization and their repositories and gists")
-	var allUsers []*github.User
-	opt2 := &github.ListMembersOptions{
-		ListOptions: github.ListOptions{PerPage: 10},
+func cloneorgrepos(ctx context.Context, provider Provider, p *pipeline, org string) error {
+
+	Info("Cloning the repositories of the organization: %s", org)
+	orgRepos, err := provider.OrgRepos(ctx, org)
+	if err != nil {
+		return err
 	}
 
-	for {
-		users, resp, err := client.Organizations.ListMembers(ctx, org, opt2)
-		check(err)
-		allUsers = append(allUsers, users...) //adding to the allUsers array
-		if resp.NextPage == 0 {
-			break
+	//iterating through the repo array
+	for _, repo := range orgRepos {
+		if *scanPrivateReposOnly && !repo.Private {
+			continue
 		}
-		opt2.Page = resp.NextPage
+		enqueueClone(p, repo, reposDir("org", repo.Name), org)
 	}
 
-	return allUsers, nil
-}
-
-func runGitsecrets(filepath string, reponame string, orgoruser string) error {
-	outputFile2 := "/tmp/results/gitsecrets/" + orgoruser + "_" + reponame + "_" + uuid.NewV4().String() + ".txt"
-	cmd2 := exec.Command("./rungitsecrets.sh", filepath, outputFile2)
-	var out2 bytes.Buffer
-	cmd2.Stdout = &out2
-	err2 := cmd2.Run()
-	check(err2)
 	return nil
 }
 
-func runTrufflehog(filepath string, reponame string, orgoruser string) error {
-	outputFile1 := "/tmp/results/thog/" + orgoruser + "_" + reponame + "_" + uuid.NewV4().String() + ".txt"
-
-	// open the out file for writing
-	outfile, fileErr := os.OpenFile(outputFile1, os.O_CREATE|os.O_RDWR, 0644)
-	check(fileErr)
-	defer outfile.Close()
-
-	cmd1 := exec.Command("python", "./truffleHog/truffleHog/truffleHog.py", "--regex", "--entropy=True", filepath)
+func cloneuserrepos(ctx context.Context, provider Provider, p *pipeline, user string) error {
+	Info("Cloning %s's repositories", user)
 
-	// direct stdout to the outfile
-	cmd1.Stdout = outfile
+	userRepos, err := provider.UserRepos(ctx, user)
+	if err != nil {
+		return err
+	}
 
-	err1 := cmd1.Run()
-	check(err1)
-	return nil
-}
+	//iterating through the userRepos array
+	for _, userRepo := range userRepos {
+		enqueueClone(p, userRepo, reposDir("users", user, userRepo.Name), user)
+	}
 
-func runReposupervisor(filepath string, reponame string, orgoruser string) error {
-	outputFile3 := "/tmp/results/repo-supervisor/" + orgoruser + "_" + reponame + "_" + uuid.NewV4().String() + ".txt"
-	cmd3 := exec.Command("./runreposupervisor.sh", filepath, outputFile3)
-	var out3 bytes.Buffer
-	cmd3.Stdout = &out3
-	err3 := cmd3.Run()
-	check(err3)
 	return nil
 }
 
-func runGitTools(tool string, filepath string, wg *sync.WaitGroup, reponame string, orgoruser string) {
-	defer wg.Done()
+func cloneusergists(ctx context.Context, provider Provider, p *pipeline, user string) error {
+	Info("Cloning %s's gists", user)
 
-	switch tool {
-	case "all":
-		err := runGitsecrets(filepath, reponame, orgoruser)
-		check(err)
-		err = runTrufflehog(filepath, reponame, orgoruser)
-		check(err)
-		err = runReposupervisor(filepath, reponame, orgoruser)
-		check(err)
+	userGists, err := provider.UserGists(ctx, user)
+	if err != nil {
+		return err
+	}
 
-	case "gitsecrets":
-		err := runGitsecrets(filepath, reponame, orgoruser)
-		check(err)
+	//iterating through the userGists array
+	for _, userGist := range userGists {
+		enqueueClone(p, userGist, reposDir("users", user, userGist.Name), user)
+	}
 
-	case "thog":
-		err := runTrufflehog(filepath, reponame, orgoruser)
-		check(err)
+	return nil
+}
 
-	case "repo-supervisor":
-		err := runReposupervisor(filepath, reponame, orgoruser)
-		check(err)
-	}
+func listallusers(ctx context.Context, provider Provider, org string) ([]string, error) {
+	Info("Listing users of the organization and their repositories and gists")
+	return provider.OrgMembers(ctx, org)
 }
 
-func scanforeachuser(user string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	var wguserrepogist sync.WaitGroup
+// runGitTools runs every selected tool on the repository at filepath, which was cloned from repoURL.
+// It returns false if any of them failed.
+func runGitTools(filepath string, repoURL string, reponame string, orgoruser string, commits commitRange) bool {
+	uri := repositoryURI(repoURL)
 
-	gituserrepos, _ := ioutil.ReadDir("/tmp/repos/users/" + user)
-	for _, f := range gituserrepos {
-		wguserrepogist.Add(1)
-		func (user string, wg *sync.WaitGroup,wguserrepogist *sync.WaitGroup, f os.FileInfo) {
-			enqueueJob(func(){
-				runGitTools(*toolName, "/tmp/repos/users/"+user+"/"+f.Name()+"/", wguserrepogist, f.Name(), user)
-			})
-		}(user, wg, &wguserrepogist, f)
+	ok := true
+	for _, s := range activeScanners {
+		findings, err := s.Scan(filepath, reponame, orgoruser, commits)
+		if err != nil {
+			results.addError("scan", s.Name(), orgoruser, reponame, err)
+			ok = false
+		}
+		for i := range findings {
+			findings[i].RepoURL = uri
+		}
+		results.addFindings(findings)
 	}
-	wguserrepogist.Wait()
+	return ok
 }
 
-func toolsOutput(toolname string, of *os.File) error {
+func toolsOutput(toolname string, findings []Finding, of *os.File) error {
 
 	linedelimiter := "----------------------------------------------------------------------------" +
 		"----------------------------------------------------------------------------" +
@@ -327,90 +307,87 @@ func toolsOutput(toolname string, of *os.File) error {
 	_, err := of.WriteString("Tool: " + toolname + "\n")
 	check(err)
 
-	results, _ := ioutil.ReadDir("/tmp/results/" + toolname + "/")
-	for _, f := range results {
-		file, err := os.Open("/tmp/results/" + toolname + "/" + f.Name())
-		check(err)
-
-		fi, err := file.Stat()
-		check(err)
-
-		if fi.Size() == 0 {
-			continue
-		} else if fi.Size() > 0 {
-			fname := strings.Split(f.Name(), "_")
-			orgoruserstr := fname[0]
-			rnamestr := fname[1]
-
-			_, err1 := of.WriteString("OrgorUser: " + orgoruserstr + " RepoName: " + rnamestr + "\n")
-			check(err1)
-
-			if _, err2 := io.Copy(of, file); err2 != nil {
-				return err2
-			}
-
-			_, err3 := of.WriteString(linedelimiter + "\n")
-			check(err3)
-
-			of.Sync()
-
-		}
-		defer file.Close()
-
-	}
+	findingsOutput(toolname, findings, of, linedelimiter)
 
 	return nil
 }
 
-func singletoolOutput(toolname string, of *os.File) error {
+func singletoolOutput(toolname string, findings []Finding, of *os.File) error {
 
-	results, _ := ioutil.ReadDir("/tmp/results/" + toolname + "/")
-	for _, f := range results {
-		file, err := os.Open("/tmp/results/" + toolname + "/" + f.Name())
-		check(err)
+	findingsOutput(toolname, findings, of, "")
 
-		fi, err := file.Stat()
-		check(err)
+	return nil
+}
 
-		if fi.Size() == 0 {
+// findingsOutput writes the findings of a tool as text, grouped by the repository they were found in
+func findingsOutput(toolname string, findings []Finding, of *os.File, linedelimiter string) {
+	var last Finding
+	for _, f := range findings {
+		if f.Tool != toolname {
 			continue
-		} else if fi.Size() > 0 {
-
-			if _, err2 := io.Copy(of, file); err2 != nil {
-				return err2
+		}
+		if last.Tool == "" || f.OrgOrUser != last.OrgOrUser || f.Repo != last.Repo {
+			if last.Tool != "" && linedelimiter != "" {
+				of.WriteString(linedelimiter + "\n")
 			}
-			of.Sync()
+			of.WriteString("OrgorUser: " + f.OrgOrUser + " RepoName: " + f.Repo + "\n")
 		}
-		defer file.Close()
+		writeFindingText(of, f)
+		last = f
 	}
-
-	return nil
+	if last.Tool != "" && linedelimiter != "" {
+		of.WriteString(linedelimiter + "\n")
+	}
+	of.Sync()
 }
 
-func combineOutput(toolname string, outputfile string) error {
-	// Read all files in /tmp/results/<tool-name>/ directories for all the tools
-	// open a new file and save it in the output directory - outputFile
-	// for each results file, write user/org and reponame, copy results from the file in the outputFile, end with some delimiter
+func combineOutput(selected []Scanner, outputfile string, format string) error {
+	// Write the findings of all the tools to the output file in the requested format.
+	// For the text format, the findings are grouped by tool and then by user/org and reponame.
+	// The same leak found by several tools or in several clones of a fork is only written once.
 
 	of, err := os.Create(outputfile)
 	check(err)
 
-	switch toolname {
-	case "all":
-		tools := []string{"thog", "gitsecrets", "repo-supervisor"}
+	current := dedupFindings(results.findings)
 
-		for _, tool := range tools {
-			err = toolsOutput(tool, of)
-			check(err)
-		}
-	case "gitsecrets":
-		err = singletoolOutput("gitsecrets", of)
+	// drop or mark the findings that were already reported in the baseline
+	findings := current
+	if *baselineFile != "" {
+		known, err := loadBaseline(*baselineFile)
+		check(err)
+		findings = applyBaseline(current, known, *showKnown)
+	}
+
+	switch format {
+	case "json":
+		err = writeJSON(of, findings, results.errors)
+		check(err)
+	case "ndjson":
+		err = writeNDJSON(of, findings, results.errors)
 		check(err)
-	case "thog":
-		err = singletoolOutput("thog", of)
+	case "sarif":
+		err = writeSARIF(of, selected, findings, results.errors)
 		check(err)
-	case "repo-supervisor":
-		err = singletoolOutput("repo-supervisor", of)
+	default:
+		if len(selected) == 1 {
+			err = singletoolOutput(selected[0].Name(), findings, of)
+			check(err)
+		} else {
+			for _, s := range selected {
+				err = toolsOutput(s.Name(), findings, of)
+				check(err)
+			}
+		}
+		writeErrorsText(of, results.errors)
+	}
+
+	if *updateBaseline {
+		Info("Saving the findings of this run as the baseline: %s", *baselineFile)
+		// with -stateFile, only the new commits were scanned, so every previous finding is kept
+		err = writeBaseline(*baselineFile, current, func(f Finding) bool {
+			return state != nil || results.failed(f.OrgOrUser, f.Repo)
+		})
 		check(err)
 	}
 
@@ -430,10 +407,14 @@ func scanDir(dir string, org string) error {
 
 	allRepos, _ := ioutil.ReadDir(dir)
 	for _, f := range allRepos {
+		if !f.IsDir() {
+			continue
+		}
 		wg.Add(1)
 		func (f os.FileInfo, wg *sync.WaitGroup, org string) {
 			enqueueJob(func () {
-				runGitTools(*toolName, dir+f.Name()+"/", wg, f.Name(), org)
+				defer wg.Done()
+				runGitTools(dir+f.Name()+"/", dir+f.Name(), f.Name(), org, commitRange{})
 			})
 		}(f, &wg, org)
 	}
@@ -441,12 +422,88 @@ func scanDir(dir string, org string) error {
 	return nil
 }
 
-func scanorgrepos(org string) error {
-	err := scanDir("/tmp/repos/org/", org)
-	check(err)
+// exitCode returns the exit code of the run for the errors that happened during it.
+// With the "scan" policy, only a tool failing on a repository counts. Repositories
+// that couldn't be listed or cloned are reported but don't fail the run.
+func exitCode(policy string, errors []scanError) int {
+	for _, e := range errors {
+		if policy == "any" || (policy == "scan" && e.Stage == "scan") {
+			return 1
+		}
+	}
+	return 0
+}
+
+// isRepoDir reports whether dir is a git repository, either a working tree or a bare one
+func isRepoDir(dir string) bool {
+	_, err := git.PlainOpen(dir)
+	return err == nil
+}
+
+// scanLocalPath scans a directory that is already on disk. If dir is a repository, or contains no
+// repositories at all, it is scanned as one repository named after it. Otherwise every directory
+// in it is scanned as a repository, like the cloned repositories of an org.
+func scanLocalPath(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	var repoDirs int
+	if !isRepoDir(dir) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range entries {
+			if f.IsDir() && isRepoDir(filepath.Join(dir, f.Name())) {
+				repoDirs++
+			}
+		}
+	}
+
+	if repoDirs > 0 {
+		Info("Scanning the %d repositories in: %s\n", repoDirs, dir)
+		return scanDir(dir+"/", filepath.Base(dir))
+	}
+
+	Info("Scanning: %s\n", dir)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	enqueueJob(func() {
+		defer wg.Done()
+		runGitTools(dir+"/", dir, filepath.Base(dir), filepath.Base(filepath.Dir(dir)), commitRange{})
+	})
+	wg.Wait()
 	return nil
 }
 
+// parseRepoURL returns the owner and name of the repository or gist at repoURL. It works for
+// HTTPS, SSH, git and file URLs on any host, including Github Enterprise where gist URLs look like
+// https://github.example.com/gist/user/id. The owner is the path segment before the name.
+func parseRepoURL(repoURL string) (owner string, name string) {
+	path := repoURL
+	if i := strings.Index(path, "://"); i != -1 {
+		path = path[i+3:]
+		if j := strings.Index(path, "/"); j != -1 {
+			path = path[j+1:]
+		} else {
+			path = ""
+		}
+	} else if i := strings.Index(path, ":"); i != -1 {
+		// scp like SSH URL, e.g. git@github.com:owner/repo.git
+		path = path[i+1:]
+	}
+
+	// a local repository can be given as its .git directory, e.g. file:///srv/repo/.git
+	segments := strings.Split(strings.TrimSuffix(strings.Trim(path, "/"), "/.git"), "/")
+	name = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	if len(segments) > 1 {
+		owner = segments[len(segments)-2]
+	}
+	return owner, name
+}
+
 func stringInSlice(a string, list []*github.Repository) (bool, error) {
 	for _, b := range list {
 		if *b.SSHURL == a || *b.CloneURL == a {
@@ -456,12 +513,45 @@ func stringInSlice(a string, list []*github.Repository) (bool, error) {
 	return false, nil
 }
 
-func checkflags(token string, org string, user string, repoURL string, gistURL string, teamName string, scanPrivateReposOnly bool, orgOnly bool, toolName string) error {
-	if token == "" {
+func checkflags(token string, org string, user string, repoURL string, gistURL string, teamName string, scanPrivateReposOnly bool, orgOnly bool, toolName string, format string, baselineFile string, updateBaseline bool, errorPolicy string, providerName string, giteaURL string, localPath string, targetsFile string) error {
+	if targetsFile != "" && (org != "" || user != "" || repoURL != "" || gistURL != "" || localPath != "") {
+		fmt.Println("Can't have targets along with any of org, user, repoURL, gistURL or path. Please provide just one of these values")
+		os.Exit(2)
+	} else if localPath != "" && (org != "" || user != "" || repoURL != "" || gistURL != "") {
+		fmt.Println("Can't have path along with any of org, user, repoURL or gistURL. Please provide just one of these values")
+		os.Exit(2)
+	} else if fi, err := os.Stat(localPath); localPath != "" && (err != nil || !fi.IsDir()) {
+		fmt.Println("path should be an existing directory")
+		os.Exit(2)
+	} else if *githubAppID != 0 && (*githubAppKey == "" || providerName != "github") {
+		fmt.Println("githubAppID should be used along with the githubAppKey flag and the github provider")
+		os.Exit(2)
+	} else if token == "" && *githubAppID == 0 && localPath == "" && targetsFile == "" {
 		fmt.Println("Need a Github personal access token. Please provide that using the -token flag")
 		os.Exit(2)
-	} else if org == "" && user == "" && repoURL == "" && gistURL == "" {
-		fmt.Println("org, user, repoURL and gistURL can't all be empty. Please provide just one of these values")
+	} else if !(providerName == "github" || providerName == "gitlab" || providerName == "bitbucket" || providerName == "gitea") {
+		fmt.Println("Please enter either github, gitlab, bitbucket or gitea as the provider.")
+		os.Exit(2)
+	} else if providerName == "gitea" && giteaURL == "" {
+		fmt.Println("Need the URL of the Gitea instance to scan. Please provide that using the -giteaURL flag")
+		os.Exit(2)
+	} else if _, err := selectScanners(toolName); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	} else if updateBaseline && baselineFile == "" {
+		fmt.Println("updateBaseline flag should be used along with the baseline file to update")
+		os.Exit(2)
+	} else if !(errorPolicy == "never" || errorPolicy == "scan" || errorPolicy == "any") {
+		fmt.Println("Please enter either never, scan or any as the error policy.")
+		os.Exit(2)
+	} else if *threads < 1 || *cloneThreads < 1 {
+		fmt.Println("threads and cloneThreads should be at least 1")
+		os.Exit(2)
+	} else if !(format == "text" || format == "json" || format == "ndjson" || format == "sarif") {
+		fmt.Println("Please enter either text, json, ndjson or sarif as the output format.")
+		os.Exit(2)
+	} else if org == "" && user == "" && repoURL == "" && gistURL == "" && localPath == "" && targetsFile == "" {
+		fmt.Println("org, user, repoURL, gistURL, path and targets can't all be empty. Please provide just one of these values")
 		os.Exit(2)
 	} else if org != "" && (user != "" || repoURL != "" || gistURL != "") {
 		fmt.Println("Can't have org along with any of user, repoURL or gistURL. Please provide just one of these values")
@@ -478,81 +568,92 @@ func checkflags(token string, org string, user string, repoURL string, gistURL s
 	} else if teamName != "" && org == "" {
 		fmt.Println("Can't have a teamName without an org! Please provide a value for org along with the team name")
 		os.Exit(2)
+	} else if teamName != "" && providerName != "github" {
+		fmt.Println("teamName is only supported by the github provider")
+		os.Exit(2)
 	} else if orgOnly && org == "" {
 		fmt.Println("orgOnly flag should be used with a valid org")
 		os.Exit(2)
-	} else if scanPrivateReposOnly && user == "" && repoURL == "" {
-		fmt.Println("scanPrivateReposOnly flag should be used along with either the user or the repoURL")
+	} else if scanPrivateReposOnly && org == "" && user == "" && repoURL == "" {
+		fmt.Println("scanPrivateReposOnly flag should be used along with either the org, the user or the repoURL")
 		os.Exit(2)
-	} else if scanPrivateReposOnly && (user != "" || repoURL != "") {
-		fmt.Println("scanPrivateReposOnly flag is provided with either the user or the repoURL")
+	} else if scanPrivateReposOnly && (org != "" || user != "" || repoURL != "") {
+		fmt.Println("scanPrivateReposOnly flag is provided with either the org, the user or the repoURL")
 		fmt.Println("Checking to see if the SSH key exists or not..")
 
-		fi, err := os.Stat("/root/.ssh/id_rsa")
-		if err == nil && fi.Size() > 0 {
+		if hasSSHKey() {
 			fmt.Println("SSH key exists and file size > 0 so continuing..")
-		}
-		if err != nil {
-			fmt.Println(err)
+		} else if isSSHURL(repoURL) {
+			fmt.Println("Since the repoURL is a SSH URL, it is required to have the SSH key mounted on a volume at " + sshKeyPath)
 			os.Exit(2)
+		} else {
+			fmt.Println("SSH key does not exist so private repositories will be cloned over HTTPS with the token..")
 		}
 
-		//Authenticating to Github using the token
-		ctx1 := context.Background()
-		ts1 := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		)
-		tc1 := oauth2.NewClient(ctx1, ts1)
-		client1 := github.NewClient(tc1)
-
-		var userRepos []*github.Repository
-		opt3 := &github.RepositoryListOptions{
-			Affiliation: "owner",
-			ListOptions: github.ListOptions{PerPage: 10},
-		}
-
-		for {
-			uRepos, resp, err := client1.Repositories.List(ctx1, "", opt3)
-			check(err)
-			userRepos = append(userRepos, uRepos...) //adding to the userRepos array
-			if resp.NextPage == 0 {
-				break
+		//The token can only be checked against the repositories of its owner on Github. Any member
+		//of an org can scan the private org repositories they have access to, and a Github App
+		//installation isn't a user at all
+		if providerName == "github" && org == "" && *githubAppID == 0 {
+			//Authenticating to Github using the token
+			ctx1 := context.Background()
+			pool, err := githubTokenPool(ctx1)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			tc1 := pool.Client()
+			client1, err := newGithubClient(tc1, *githubURL, *githubUploadURL)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(2)
 			}
-			opt3.Page = resp.NextPage
-		}
 
-		if user != "" {
-			fmt.Println("scanPrivateReposOnly flag is provided along with the user")
-			fmt.Println("Checking to see if the token provided belongs to the user or not..")
+			var userRepos []*github.Repository
+			opt3 := &github.RepositoryListOptions{
+				Affiliation: "owner",
+				ListOptions: github.ListOptions{PerPage: 10},
+			}
 
-			if *userRepos[0].Owner.Login == user {
-				fmt.Println("Token belongs to the user")
-			} else {
-				fmt.Println("Token does not belong to the user. Please provide the correct token for the user mentioned.")
-				os.Exit(2)
+			for {
+				uRepos, resp, err := client1.Repositories.List(ctx1, "", opt3)
+				check(err)
+				userRepos = append(userRepos, uRepos...) //adding to the userRepos array
+				if resp.NextPage == 0 {
+					break
+				}
+				opt3.Page = resp.NextPage
 			}
 
-		} else if repoURL != "" {
-			fmt.Println("scanPrivateReposOnly flag is provided along with the repoURL")
-			fmt.Println("Checking to see if the repo provided belongs to the user or not..")
-			val, err := stringInSlice(repoURL, userRepos)
-			check(err)
-			if val {
-				fmt.Println("Repo belongs to the user provided")
-			} else {
-				fmt.Println("Repo does not belong to the user whose token is provided. Please provide a valid repoURL that belongs to the user whose token is provided.")
-				os.Exit(2)
+			if user != "" {
+				fmt.Println("scanPrivateReposOnly flag is provided along with the user")
+				fmt.Println("Checking to see if the token provided belongs to the user or not..")
+
+				if *userRepos[0].Owner.Login == user {
+					fmt.Println("Token belongs to the user")
+				} else {
+					fmt.Println("Token does not belong to the user. Please provide the correct token for the user mentioned.")
+					os.Exit(2)
+				}
+
+			} else if repoURL != "" {
+				fmt.Println("scanPrivateReposOnly flag is provided along with the repoURL")
+				fmt.Println("Checking to see if the repo provided belongs to the user or not..")
+				val, err := stringInSlice(repoURL, userRepos)
+				check(err)
+				if val {
+					fmt.Println("Repo belongs to the user provided")
+				} else {
+					fmt.Println("Repo does not belong to the user whose token is provided. Please provide a valid repoURL that belongs to the user whose token is provided.")
+					os.Exit(2)
+				}
 			}
 		}
 
-	} else if scanPrivateReposOnly && (org != "" || gistURL != "") {
-		fmt.Println("scanPrivateReposOnly flag should not be provided with either the org or the gistURL since its a private repository or multiple private repositories that we are looking to scan. Please provide either a user or a private repoURL")
-		os.Exit(2)
-	} else if !(toolName == "thog" || toolName == "gitsecrets" || toolName == "repo-supervisor" || toolName == "all") {
-		fmt.Println("Please enter either thog, gitsecrets, repo-supervisor or all.")
+	} else if scanPrivateReposOnly && gistURL != "" {
+		fmt.Println("scanPrivateReposOnly flag should not be provided with the gistURL since its a private repository or multiple private repositories that we are looking to scan. Please provide either an org, a user or a private repoURL")
 		os.Exit(2)
 	} else if repoURL != "" && !scanPrivateReposOnly {
-		if strings.Split(repoURL, "@")[0] == "git" {
+		if isSSHURL(repoURL) {
 			fmt.Println("Since the repoURL is a SSH URL, it is required to have the scanPrivateReposOnly flag and the SSH key mounted on a volume")
 			os.Exit(2)
 		}
@@ -561,90 +662,107 @@ func checkflags(token string, org string, user string, repoURL string, gistURL s
 	return nil
 }
 
-func makeDirectories() error {
-	os.MkdirAll("/tmp/repos/org", 0700)
-	os.MkdirAll("/tmp/repos/team", 0700)
-	os.MkdirAll("/tmp/repos/users", 0700)
-	os.MkdirAll("/tmp/repos/singlerepo", 0700)
-	os.MkdirAll("/tmp/repos/singlegist", 0700)
-	os.MkdirAll("/tmp/results/thog", 0700)
-	os.MkdirAll("/tmp/results/gitsecrets", 0700)
-	os.MkdirAll("/tmp/results/repo-supervisor", 0700)
+// runDir is the directory of this run under -workdir. Every run gets its own, so that two runs on
+// the same host don't overwrite each other's clones and results.
+var runDir string
 
-	return nil
+// reposDir returns the path under the run directory where repositories are cloned to
+func reposDir(elem ...string) string {
+	return filepath.Join(append([]string{runDir, "repos"}, elem...)...)
 }
 
-func findTeamByName(ctx context.Context, client *github.Client, org string, teamName string) (*github.Team, error) {
+// resultsDir returns the path under the run directory where external tools write their raw output
+func resultsDir(elem ...string) string {
+	return filepath.Join(append([]string{runDir, "results"}, elem...)...)
+}
 
-	listTeamsOpts := &github.ListOptions{
-		PerPage: 10,
+func makeDirectories() error {
+	err := os.MkdirAll(*workdir, 0700)
+	if err != nil {
+		return err
 	}
-	Info("Listing teams...")
-	for {
-		teams, resp, err := client.Organizations.ListTeams(ctx, org, listTeamsOpts)
-		check(err)
-		//check the name here--try to avoid additional API calls if we've found the team
-		for _, team := range teams {
-			if *team.Name == teamName {
-				return team, nil
-			}
-		}
-		if resp.NextPage == 0 {
-			break
-		}
-		listTeamsOpts.Page = resp.NextPage
+	runDir, err = ioutil.TempDir(*workdir, "git-all-secrets-")
+	if err != nil {
+		return err
+	}
+	// the external tools cd into the repository, so they need an absolute path to write their output to
+	runDir, err = filepath.Abs(runDir)
+	if err != nil {
+		return err
 	}
-	return nil, nil
-}
-
-func cloneTeamRepos(ctx context.Context, client *github.Client, org string, teamName string) error {
 
-	// var team *github.Team
-	team, err := findTeamByName(ctx, client, org, teamName)
+	for _, dir := range []string{"org", "team", "users", "singlerepo", "singlegist", "targets"} {
+		os.MkdirAll(reposDir(dir), 0700)
+	}
+	for _, name := range scannerNames() {
+		os.MkdirAll(resultsDir(name), 0700)
+	}
 
-	if team != nil {
-		Info("Cloning the repositories of the team: " + *team.Name + "(" + strconv.Itoa(*team.ID) + ")")
-		var teamRepos []*github.Repository
-		listTeamRepoOpts := &github.ListOptions{
-			PerPage: 10,
-		}
+	return nil
+}
 
-		Info("Listing team repositories...")
-		for {
-			repos, resp, err := client.Organizations.ListTeamRepos(ctx, *team.ID, listTeamRepoOpts)
-			check(err)
-			teamRepos = append(teamRepos, repos...) //adding to the repo array
-			if resp.NextPage == 0 {
-				break
-			}
-			listTeamRepoOpts.Page = resp.NextPage
-		}
+// cleanup removes the run directory once the output is written, except for the clones and raw
+// tool results that -keepClones and -keepRawResults ask to keep
+func cleanup() {
+	if !*keepClones {
+		os.RemoveAll(reposDir())
+	}
+	if !*keepRawResults {
+		os.RemoveAll(resultsDir())
+	}
 
-		var teamrepowg sync.WaitGroup
+	if *keepClones || *keepRawResults {
+		Info("The clones and raw results that were kept are in %s", runDir)
+	} else {
+		os.Remove(runDir)
+	}
+}
 
-		//iterating through the repo array
-		for _, repo := range teamRepos {
-			teamrepowg.Add(1)
-			go executeclone(repo, "/tmp/repos/team/"+*repo.Name, &teamrepowg)
-		}
+func cloneTeamRepos(ctx context.Context, provider Provider, p *pipeline, org string, teamName string) error {
 
-		teamrepowg.Wait()
-		fmt.Println("")
+	teams, ok := provider.(teamLister)
+	if !ok {
+		return fmt.Errorf("%s doesn't support teams", *providerName)
+	}
 
-	} else {
+	teamRepos, err := teams.TeamRepos(ctx, org, teamName)
+	if err == errTeamNotFound {
 		fmt.Println("Unable to find the team '" + teamName + "'; perhaps the user is not a member?\n")
-		if err != nil {
-			fmt.Println("Error was:")
-			fmt.Println(err)
-		}
 		os.Exit(2)
+	} else if err != nil {
+		return err
 	}
+
+	//iterating through the repo array. Repos that were already listed with the org or another
+	//team are only scanned once by the pipeline
+	for _, repo := range teamRepos {
+		if *scanPrivateReposOnly && !repo.Private {
+			continue
+		}
+		enqueueClone(p, repo, reposDir("team", repo.Name), org)
+	}
+
 	return nil
 }
 
-func scanTeamRepos(org string) error {
-	err := scanDir("/tmp/repos/team/", org)
-	check(err)
+// cloneUserTeamsRepos clones the repositories of every team in org the token's user is a member of.
+// Private repositories that a member can only access through a team aren't always listed with the
+// org, so this is the fallback when scanning the private repositories of an org without a teamName.
+func cloneUserTeamsRepos(ctx context.Context, provider Provider, p *pipeline, org string) error {
+	teams, ok := provider.(teamLister)
+	if !ok {
+		return nil
+	}
+
+	teamNames, err := teams.UserTeams(ctx, org)
+	if err != nil {
+		return err
+	}
+	for _, teamName := range teamNames {
+		if err := cloneTeamRepos(ctx, provider, p, org, teamName); err != nil {
+			results.addError("list", "", org, "", err)
+		}
+	}
 	return nil
 }
 
@@ -656,109 +774,129 @@ func main() {
 	executionQueue = make(chan bool, *threads)
 
 	//Logic to check the program is ingesting proper flags
-	err := checkflags(*token, *org, *user, *repoURL, *gistURL, *teamName, *scanPrivateReposOnly, *orgOnly, *toolName)
+	err := checkflags(*token, *org, *user, *repoURL, *gistURL, *teamName, *scanPrivateReposOnly, *orgOnly, *toolName, *format, *baselineFile, *updateBaseline, *errorPolicy, *providerName, *giteaURL, *localPath, *targetsFile)
 	check(err)
 
-	//Authenticating to Github using the token
+	activeScanners, err = selectScanners(*toolName)
+	check(err)
+
+	//Authenticating to the provider using the token. Local directories and targets are scanned without one
 	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: *token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+	var provider Provider
+	if *localPath == "" && *targetsFile == "" {
+		provider, err = newProvider(ctx, *providerName, *token)
+		check(err)
+	}
 
-	//Creating some temp directories to store repos & results. These will be deleted in the end
+	//Creating a directory for this run to store repos & results. It is deleted in the end
 	err = makeDirectories()
 	check(err)
 
-	//By now, we either have the org, user, repoURL or the gistURL. The program flow changes accordingly..
+	//Loading what previous runs scanned, to only scan the new commits
+	if *stateFile != "" {
+		state, err = loadState(*stateFile)
+		check(err)
+	}
+
+	//Every repo is scanned as soon as it is cloned and deleted afterwards
+	p := newPipeline(*cloneThreads, *threads, *maxDiskMB<<20)
 
-	if *org != "" { //If org was supplied
+	//By now, we either have the org, user, repoURL, gistURL, path or the targets. The program flow changes accordingly..
+
+	if *targetsFile != "" { //If a file of git URLs was supplied
+		targets, err := readTargets(*targetsFile, reposDir("targets"))
+		check(err)
+
+		Info("Since targets were provided, the tool will clone and scan the %d git URLs in %s\n", len(targets), *targetsFile)
+		for _, t := range targets {
+			p.add(cloneJob{url: t.URL, dir: t.Dir, name: t.Name, orgoruser: t.Owner})
+		}
+
+	} else if *localPath != "" { //If a local directory was supplied
+		Info("Since path was provided, the tool will scan the repositories on disk without cloning anything\n")
+		err = scanLocalPath(*localPath)
+		check(err)
+		Info("Finished scanning: %s\n", *localPath)
+
+	} else if *org != "" { //If org was supplied
 		m := "Since org was provided, the tool will proceed to scan all the org repos, then all the user repos and user gists in a recursive manner"
 
 		if *orgOnly {
 			m = "Org was specified combined with orgOnly, the tool will proceed to scan only the org repos and nothing related to its users"
+		} else if *scanPrivateReposOnly {
+			m = "Org was specified combined with scanPrivateReposOnly, the tool will proceed to scan only the private org repos the token has access to. Private repos of users can't be listed so they are skipped"
 		}
 
-		Info(m)
+		//only the private repos of the token's own user can be listed, so users are skipped for private scans
+		scanUsers := !*orgOnly && !*scanPrivateReposOnly
+
+		Info("%s", m)
 
 		//cloning all the repos of the org
-		err := cloneorgrepos(ctx, client, *org)
-		check(err)
+		err := cloneorgrepos(ctx, provider, p, *org)
+		if err != nil {
+			results.addError("list", "", *org, "", err)
+		}
 
 		if *teamName != "" { //If team was supplied
 			Info("Since team name was provided, the tool will clone all repos to which the team has access")
 
 			//cloning all the repos of the team
-			err := cloneTeamRepos(ctx, client, *org, *teamName)
-			check(err)
+			err := cloneTeamRepos(ctx, provider, p, *org, *teamName)
+			if err != nil {
+				results.addError("list", "", *org, "", err)
+			}
 
+		} else if *scanPrivateReposOnly {
+			Info("Cloning the private repos of the teams the token's user is a member of, in case the org listing didn't include all of them")
+
+			err := cloneUserTeamsRepos(ctx, provider, p, *org)
+			if err != nil {
+				results.addError("list", "", *org, "", err)
+			}
 		}
 
-		//getting all the users of the org into the allUsers array
-		allUsers, err := listallusers(ctx, client, *org)
-		check(err)
+		if scanUsers {
 
-		if !*orgOnly {
+			//getting all the users of the org into the allUsers array
+			allUsers, err := listallusers(ctx, provider, *org)
+			if err != nil {
+				results.addError("list", "", *org, "", err)
+			}
 
 			//iterating through the allUsers array
 			for _, user := range allUsers {
 
 				//cloning all the repos of a user
-				err1 := cloneuserrepos(ctx, client, *user.Login)
-				check(err1)
+				err1 := cloneuserrepos(ctx, provider, p, user)
+				if err1 != nil {
+					results.addError("list", "", user, "", err1)
+				}
 
 				//cloning all the gists of a user
-				err2 := cloneusergists(ctx, client, *user.Login)
-				check(err2)
+				err2 := cloneusergists(ctx, provider, p, user)
+				if err2 != nil {
+					results.addError("list", "", user, "", err2)
+				}
 
 			}
 		}
 
-		Info("Scanning all org repositories now..This may take a while so please be patient\n")
-		err = scanorgrepos(*org)
-		check(err)
-		Info("Finished scanning all org repositories\n")
-
-		if *teamName != "" { //If team was supplied
-			Info("Scanning all team repositories now...This may take a while so please be patient\n")
-			err = scanTeamRepos(*org)
-			check(err)
-
-			Info("Finished scanning all team repositories\n")
-		}
-
-		if !*orgOnly {
-
-			Info("Scanning all user repositories and gists now..This may take a while so please be patient\n")
-			var wguser sync.WaitGroup
-			for _, user := range allUsers {
-				wguser.Add(1)
-				go scanforeachuser(*user.Login, &wguser)
-			}
-			wguser.Wait()
-			Info("Finished scanning all user repositories and gists\n")
-		}
-
 	} else if *user != "" { //If user was supplied
 		Info("Since user was provided, the tool will proceed to scan all the user repos and user gists\n")
-		err1 := cloneuserrepos(ctx, client, *user)
-		check(err1)
-
-		err2 := cloneusergists(ctx, client, *user)
-		check(err2)
+		err1 := cloneuserrepos(ctx, provider, p, *user)
+		if err1 != nil {
+			results.addError("list", "", *user, "", err1)
+		}
 
-		Info("Scanning all user repositories and gists now..This may take a while so please be patient\n")
-		var wguseronly sync.WaitGroup
-		wguseronly.Add(1)
-		go scanforeachuser(*user, &wguseronly)
-		wguseronly.Wait()
-		Info("Finished scanning all user repositories and gists\n")
+		err2 := cloneusergists(ctx, provider, p, *user)
+		if err2 != nil {
+			results.addError("list", "", *user, "", err2)
+		}
 
 	} else if *repoURL != "" || *gistURL != "" { //If either repoURL or gistURL was supplied
 
-		var url, repoorgist, fpath, rn, lastString, orgoruserName string
-		var bpath = "/tmp/repos/"
+		var url, repoorgist, fpath, rn, orgoruserName string
 
 		if *repoURL != "" { //repoURL
 			url = *repoURL
@@ -768,59 +906,36 @@ func main() {
 			repoorgist = "gist"
 		}
 
-		Info("The tool will proceed to clone and scan: " + url + " only\n")
-
-		splitArray := strings.Split(url, "/")
-		lastString = splitArray[len(splitArray)-1]
+		Info("The tool will proceed to clone and scan: %s only\n", url)
 
-		if !*scanPrivateReposOnly {
-			orgoruserName = splitArray[3]
-		} else {
-			tempstr := splitArray[0]
-			tempstr2 := strings.Split(tempstr, ":")
-			orgoruserName = tempstr2[1]
-		}
+		orgoruserName, rn = parseRepoURL(url)
 
 		switch repoorgist {
 		case "repo":
-			rn = strings.Split(lastString, ".")[0]
-			fpath = bpath + "singlerepo/" + rn
+			fpath = reposDir("singlerepo", rn)
 		case "gist":
-			rn = lastString
-			fpath = bpath + "singlegist/" + lastString
+			fpath = reposDir("singlegist", rn)
 		}
 
-		//cloning
-		Info("Starting to clone: " + url + "\n")
-		var wgo sync.WaitGroup
-		wgo.Add(1)
-		func (url string, fpath string,wgo *sync.WaitGroup ) {
-			enqueueJob(func() {
-				gitclone(url, fpath, wgo)
-			})
-		}(url, fpath, &wgo)
-		wgo.Wait()
-		Info("Cloning of: " + url + " finished\n")
-
-		//scanning
-		Info("Starting to scan: " + url + "\n")
-		var wgs sync.WaitGroup
-		wgs.Add(1)
-
-		func (rn string, fpath string,wgs *sync.WaitGroup, orgoruserName string ) {
-			enqueueJob(func() {
-				runGitTools(*toolName, fpath+"/", wgs, rn, orgoruserName)
-			})
-		}(rn, fpath, &wgs, orgoruserName)
-
-		wgs.Wait()
-		Info("Scanning of: " + url + " finished\n")
-
+		p.add(cloneJob{url: url, dir: fpath, name: rn, orgoruser: orgoruserName})
 	}
 
+	Info("Waiting for the remaining repositories to be cloned and scanned..This may take a while so please be patient\n")
+	p.wait()
+	Info("Finished scanning all repositories\n")
+
 	//Now, that all the scanning has finished, time to combine the output
 	Info("Combining the output into one file\n")
-	err = combineOutput(*toolName, *outputFile)
+	err = combineOutput(activeScanners, *outputFile, *format)
 	check(err)
+	if state != nil {
+		err = state.save()
+		check(err)
+	}
+	cleanup()
 
+	if len(results.errors) > 0 {
+		Info("%d errors happened during the run. They are listed at the end of %s", len(results.errors), *outputFile)
+	}
+	os.Exit(exitCode(*errorPolicy, results.errors))
 }
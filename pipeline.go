@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cloneJob is a repository to clone into dir and scan. Findings are reported under orgoruser.
+type cloneJob struct {
+	url       string
+	dir       string
+	name      string
+	orgoruser string
+	pushedAt  time.Time
+}
+
+// scanJob is a cloned repository waiting to be scanned, along with the disk space it takes
+type scanJob struct {
+	cloneJob
+	size int64
+}
+
+// pipeline clones and scans repositories as they are listed. Every repository is scanned as soon
+// as its clone completes and deleted right after unless -keepClones is set, so the disk only holds the repositories that
+// are being cloned or waiting to be scanned. Cloning and scanning have their own bounded pools of
+// workers, and adding a repository blocks while all the cloners are busy.
+type pipeline struct {
+	clones  chan cloneJob
+	scans   chan scanJob
+	disk    *diskBudget
+	cloneWG sync.WaitGroup
+	scanWG  sync.WaitGroup
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newPipeline(cloneWorkers int, scanWorkers int, maxDisk int64) *pipeline {
+	p := &pipeline{
+		clones: make(chan cloneJob),
+		scans:  make(chan scanJob, scanWorkers),
+		disk:   newDiskBudget(maxDisk),
+		seen:   make(map[string]bool),
+	}
+	for i := 0; i < cloneWorkers; i++ {
+		p.cloneWG.Add(1)
+		go p.cloneWorker()
+	}
+	for i := 0; i < scanWorkers; i++ {
+		p.scanWG.Add(1)
+		go p.scanWorker()
+	}
+	return p
+}
+
+// add queues a repository to be cloned and scanned. A repository that was already added, e.g.
+// through both the org and a team, is only scanned once.
+func (p *pipeline) add(job cloneJob) {
+	p.mu.Lock()
+	if p.seen[job.url] {
+		p.mu.Unlock()
+		return
+	}
+	p.seen[job.url] = true
+	p.mu.Unlock()
+
+	p.clones <- job
+}
+
+// wait blocks until every repository that was added has been cloned and scanned
+func (p *pipeline) wait() {
+	close(p.clones)
+	p.cloneWG.Wait()
+	close(p.scans)
+	p.scanWG.Wait()
+}
+
+func (p *pipeline) cloneWorker() {
+	defer p.cloneWG.Done()
+
+	for job := range p.clones {
+		p.disk.wait()
+
+		Info("Cloning: %s", job.url)
+		if err := gitclone(job.url, job.dir); err != nil {
+			results.addError("clone", "", job.orgoruser, job.name, err)
+			continue
+		}
+
+		size := dirSize(job.dir)
+		p.disk.add(size)
+		p.scans <- scanJob{cloneJob: job, size: size}
+	}
+}
+
+func (p *pipeline) scanWorker() {
+	defer p.scanWG.Done()
+
+	for job := range p.scans {
+		if runGitTools(job.dir+"/", job.url, job.name, job.orgoruser, scanRange(job.url)) {
+			// a repository that failed to scan is scanned from the same commits again next time
+			if err := recordScan(job.url, job.dir, job.pushedAt); err != nil {
+				results.addError("state", "", job.orgoruser, job.name, err)
+			}
+		}
+
+		if !*keepClones {
+			os.RemoveAll(job.dir)
+		}
+		p.disk.release(job.size)
+	}
+}
+
+// diskBudget keeps track of the disk space taken by the clones. No new clone starts while the
+// clones on disk take max bytes or more, until scanning and deleting them frees up space. The
+// size of a repository is only known once it is cloned, so the clones in progress can take the
+// disk use over the budget. A max of 0 means no limit.
+type diskBudget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int64
+	max  int64
+}
+
+func newDiskBudget(max int64) *diskBudget {
+	b := &diskBudget{max: max}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *diskBudget) wait() {
+	b.mu.Lock()
+	for b.max > 0 && b.used >= b.max {
+		b.cond.Wait()
+	}
+	b.mu.Unlock()
+}
+
+func (b *diskBudget) add(n int64) {
+	b.mu.Lock()
+	b.used += n
+	b.mu.Unlock()
+}
+
+func (b *diskBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// dirSize returns the total size of the files in dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Repo is a repository, gist or snippet to clone and scan, as listed by a Provider
+type Repo struct {
+	Name     string
+	Owner    string
+	CloneURL string
+	SSHURL   string
+	Fork     bool
+	Private  bool
+	PushedAt time.Time
+}
+
+// Provider lists the repositories to scan on a code hosting service. Org is whatever groups
+// repositories and users on the service, like a Github organization or a Gitlab group.
+type Provider interface {
+	// OrgRepos lists the repositories that belong to org
+	OrgRepos(ctx context.Context, org string) ([]Repo, error)
+
+	// OrgMembers lists the names of the users that are members of org
+	OrgMembers(ctx context.Context, org string) ([]string, error)
+
+	// UserRepos lists the repositories of user. With -scanPrivateReposOnly, it lists the
+	// private repositories of the user the token belongs to instead.
+	UserRepos(ctx context.Context, user string) ([]Repo, error)
+
+	// UserGists lists the gists or snippets of user
+	UserGists(ctx context.Context, user string) ([]Repo, error)
+}
+
+// teamLister is implemented by the providers that can list the repositories of a team in an org
+type teamLister interface {
+	TeamRepos(ctx context.Context, org string, teamName string) ([]Repo, error)
+
+	// UserTeams lists the names of the teams in org that the user the token belongs to is in
+	UserTeams(ctx context.Context, org string) ([]string, error)
+}
+
+// newProvider returns the Provider for the code hosting service called name, authenticated with token
+func newProvider(ctx context.Context, name string, token string) (Provider, error) {
+	switch name {
+	case "github":
+		pool, err := githubTokenPool(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client, err := newGithubClient(pool.Client(), *githubURL, *githubUploadURL)
+		if err != nil {
+			return nil, err
+		}
+		return &githubProvider{client: client}, nil
+	case "gitlab":
+		return newGitlabProvider(http.DefaultClient, *gitlabURL, token), nil
+	case "bitbucket":
+		return newBitbucketProvider(http.DefaultClient, *bitbucketURL, token), nil
+	case "gitea":
+		return newGiteaProvider(http.DefaultClient, *giteaURL, token), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", name)
+}
+
+// providerToken returns the token to clone with. On Github, that is the token of the pool with the
+// most requests left, or the current installation token of a Github App, which is refreshed when it
+// expires during a long run. Without a token or an app, e.g. with -targets, it returns nothing so
+// that public repositories are cloned anonymously.
+func providerToken() (string, error) {
+	if *providerName != "github" || (*token == "" && *githubAppID == 0) {
+		return *token, nil
+	}
+
+	pool, err := githubTokenPool(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return pool.Token()
+}
+
+// providerHost returns the host that the repositories of the provider called name are cloned from
+func providerHost(name string) string {
+	var base string
+	switch name {
+	case "github":
+		base = *githubURL
+		if base == "" {
+			return "github.com"
+		}
+	case "gitlab":
+		base = *gitlabURL
+	case "bitbucket":
+		base = *bitbucketURL
+	case "gitea":
+		base = *giteaURL
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	if u.Hostname() == "api.bitbucket.org" {
+		return "bitbucket.org"
+	}
+	return u.Hostname()
+}
+
+// sendsToken reports whether the token of the provider called name is sent when cloning from host.
+// That is only the provider's own host, and gist.github.com for Github, so that repositories on
+// other hosts never receive it.
+func sendsToken(name string, host string) bool {
+	return host == providerHost(name) || (name == "github" && *githubURL == "" && host == "gist.github.com")
+}
+
+// cloneTokenAuth returns the credentials to clone from host over HTTPS with the token of the provider called name
+func cloneTokenAuth(name string, token string, host string) transport.AuthMethod {
+	if token == "" || !sendsToken(name, host) {
+		return nil
+	}
+
+	// a username:password token, like a Bitbucket app password, has its own username
+	if i := strings.Index(token, ":"); i != -1 {
+		return &githttp.BasicAuth{Username: token[:i], Password: token[i+1:]}
+	}
+
+	switch name {
+	case "github":
+		return &githttp.BasicAuth{Username: "x-access-token", Password: token}
+	case "bitbucket":
+		if host != "bitbucket.org" {
+			// Bitbucket Server HTTP access tokens are sent as a bearer token
+			return &githttp.TokenAuth{Token: token}
+		}
+		return &githttp.BasicAuth{Username: "x-token-auth", Password: token}
+	}
+	// Gitlab and Gitea accept the token as the password with any username
+	return &githttp.BasicAuth{Username: "oauth2", Password: token}
+}
+
+// getJSON sends a GET request for url with the given headers and decodes the JSON response into v.
+// The response headers are returned so that the caller can follow pagination.
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
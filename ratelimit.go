@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// maxRateLimitRetries is how many times a request that hit a secondary rate limit is retried
+const maxRateLimitRetries = 5
+
+// pooledToken is a token in a tokenPool along with what Github last said about its rate limit.
+// remaining is -1 until the first response for the token comes back.
+type pooledToken struct {
+	src       oauth2.TokenSource
+	remaining int
+	reset     time.Time
+}
+
+// tokenPool is an http.RoundTripper that authenticates every request to the Github API with the
+// token that has the most requests left. When every token is drained, it sleeps until the first
+// one is reset. Requests that hit a secondary rate limit are retried with backoff.
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens []*pooledToken
+	next   http.RoundTripper
+	sleep  func(time.Duration)
+}
+
+var (
+	githubPoolOnce sync.Once
+	githubPool     *tokenPool
+	githubPoolErr  error
+)
+
+// githubTokenPool returns the pool of tokens shared by every request to Github during the run
+func githubTokenPool(ctx context.Context) (*tokenPool, error) {
+	githubPoolOnce.Do(func() {
+		var sources []oauth2.TokenSource
+		sources, githubPoolErr = githubTokenSources(ctx)
+		if githubPoolErr == nil {
+			githubPool = newTokenPool(sources, http.DefaultTransport)
+		}
+	})
+	return githubPool, githubPoolErr
+}
+
+func newTokenPool(sources []oauth2.TokenSource, next http.RoundTripper) *tokenPool {
+	p := &tokenPool{next: next, sleep: time.Sleep}
+	for _, src := range sources {
+		p.tokens = append(p.tokens, &pooledToken{src: src, remaining: -1})
+	}
+	return p
+}
+
+// Client returns an HTTP client that sends its requests through the pool
+func (p *tokenPool) Client() *http.Client {
+	return &http.Client{Transport: p}
+}
+
+// best returns the token with the most requests left, or the earliest time one is reset if
+// they are all drained
+func (p *tokenPool) best(now time.Time) (*pooledToken, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *pooledToken
+	var bestLeft int
+	var reset time.Time
+	for _, t := range p.tokens {
+		left := t.remaining
+		if left == -1 || now.After(t.reset) {
+			// a token that has been reset or not used yet has its whole limit left
+			left = math.MaxInt32
+		}
+		if left == 0 {
+			if reset.IsZero() || t.reset.Before(reset) {
+				reset = t.reset
+			}
+			continue
+		}
+		if best == nil || left > bestLeft {
+			best, bestLeft = t, left
+		}
+	}
+	return best, reset
+}
+
+// wait returns the token with the most requests left, sleeping until one is reset if needed
+func (p *tokenPool) wait() *pooledToken {
+	for {
+		t, reset := p.best(time.Now())
+		if t != nil {
+			return t
+		}
+		Info("All tokens hit the Github rate limit, sleeping until %s", reset.Format(time.RFC1123))
+		p.sleep(time.Until(reset) + time.Second)
+	}
+}
+
+// Token returns the token with the most requests left without waiting, e.g. to clone with
+func (p *tokenPool) Token() (string, error) {
+	t, _ := p.best(time.Now())
+	if t == nil {
+		t = p.tokens[0]
+	}
+	tok, err := t.src.Token()
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// update records the rate limit of t from the headers of a response
+func (p *tokenPool) update(t *pooledToken, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	p.mu.Unlock()
+}
+
+// secondaryRateLimit returns how long to wait before retrying a response that hit a secondary
+// rate limit, and whether it did. Github asks to wait for Retry-After if set, or at least a minute
+// otherwise; backoff doubles that for every retry.
+func secondaryRateLimit(resp *http.Response, body []byte, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return 0, false
+	}
+
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	text := strings.ToLower(string(body))
+	if strings.Contains(text, "secondary rate limit") || strings.Contains(text, "abuse") {
+		return time.Minute << uint(attempt), true
+	}
+	return 0, false
+}
+
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		t := p.wait()
+		tok, err := t.src.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		r := req.WithContext(req.Context())
+		r.Header = make(http.Header, len(req.Header))
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		tok.SetAuthHeader(r)
+
+		resp, err := p.next.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		p.update(t, resp.Header)
+
+		// only requests without a body can be sent again
+		if req.Body != nil || attempt == maxRateLimitRetries ||
+			(resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests) {
+			return resp, nil
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			// the token is drained; the next attempt picks another one or sleeps until the reset
+			continue
+		}
+		if backoff, ok := secondaryRateLimit(resp, body, attempt); ok {
+			Info("Hit a Github secondary rate limit, retrying in %s", backoff)
+			p.sleep(backoff)
+			continue
+		}
+
+		resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		return resp, nil
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type reposupervisorScanner struct{}
+
+func init() {
+	registerScanner(reposupervisorScanner{})
+}
+
+func (reposupervisorScanner) Name() string { return "repo-supervisor" }
+
+// Scan ignores commits since repo-supervisor only scans the files that are checked out
+func (reposupervisorScanner) Scan(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error) {
+	outputFile := newResultPath("repo-supervisor", reponame, orgoruser)
+	// exit status 1 with output means secrets were found, like with the other tools
+	if err := runReposupervisor(filepath, outputFile); err != nil && !foundIssues(err, outputFile) {
+		return nil, err
+	}
+	return parseResultFile(outputFile, func(r io.Reader) ([]Finding, error) {
+		return parseReposupervisor(r, filepath, reponame, orgoruser)
+	})
+}
+
+func runReposupervisor(filepath string, outputFile3 string) error {
+	cmd3 := exec.Command("./runreposupervisor.sh", filepath, outputFile3)
+	var out3 bytes.Buffer
+	cmd3.Stdout = &out3
+	return cmd3.Run()
+}
+
+// reposupervisorOutput is the JSON printed by repo-supervisor when JSON_OUTPUT is set
+type reposupervisorOutput struct {
+	Result []struct {
+		Filepath string   `json:"filepath"`
+		Secrets  []string `json:"secrets"`
+	} `json:"result"`
+}
+
+// parseReposupervisor turns the JSON output of repo-supervisor into findings.
+// The file paths it prints start with the directory that was scanned, which is removed.
+func parseReposupervisor(r io.Reader, dir string, reponame string, orgoruser string) ([]Finding, error) {
+	var out reposupervisorOutput
+	if err := json.NewDecoder(r).Decode(&out); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, res := range out.Result {
+		path := res.Filepath
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+
+		for _, secret := range res.Secrets {
+			findings = append(findings, Finding{
+				Tool:      "repo-supervisor",
+				Rule:      "High Entropy",
+				OrgOrUser: orgoruser,
+				Repo:      reponame,
+				File:      path,
+				Secret:    secret,
+				Preview:   redact(secret),
+			})
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReposupervisor(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Finding
+	}{
+		{
+			name: "secrets in several files",
+			output: `{
+  "result": [
+    {
+      "filepath": "/tmp/run/org/repo/config/keys.json",
+      "secrets": [
+        "ZWY4OTgzZjQ1NjJhNGVhZGJjZDU2Nw==",
+        "9f8e7d6c5b4a39281706f5e4d3c2b1a0"
+      ]
+    },
+    {
+      "filepath": "/tmp/run/org/repo/index.js",
+      "secrets": [
+        "c2VjcmV0LXRva2VuLTEyMzQ1Njc4OTA="
+      ]
+    }
+  ]
+}
+`,
+			want: []Finding{
+				{File: "config/keys.json", Secret: "ZWY4OTgzZjQ1NjJhNGVhZGJjZDU2Nw=="},
+				{File: "config/keys.json", Secret: "9f8e7d6c5b4a39281706f5e4d3c2b1a0"},
+				{File: "index.js", Secret: "c2VjcmV0LXRva2VuLTEyMzQ1Njc4OTA="},
+			},
+		},
+		{
+			name:   "nothing found",
+			output: "{\n  \"result\": []\n}\n",
+		},
+		{
+			name:   "empty output",
+			output: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := parseReposupervisor(strings.NewReader(tt.output), "/tmp/run/org/repo/", "repo", "org")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != len(tt.want) {
+				t.Fatalf("got %d findings, want %d: %+v", len(findings), len(tt.want), findings)
+			}
+			for i, f := range findings {
+				want := tt.want[i]
+				if f.Tool != "repo-supervisor" || f.Repo != "repo" || f.OrgOrUser != "org" {
+					t.Errorf("finding %d: got tool %q in %s/%s", i, f.Tool, f.OrgOrUser, f.Repo)
+				}
+				if f.File != want.File || f.Secret != want.Secret {
+					t.Errorf("finding %d: got %+v, want %+v", i, f, want)
+				}
+			}
+		})
+	}
+}
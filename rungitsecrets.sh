@@ -1,11 +1,11 @@
 #!/bin/bash
 
-cd $1
+cd "$1" || exit
 
 git secrets --install
 git secrets --register-aws
 git secrets --add 'xoxp-.*'
 git secrets --add 'xoxb-.*'
-git secrets --scan -r . > $2
 
-exit 0
\ No newline at end of file
+# exits with 1 when a prohibited pattern is matched
+git secrets --scan -r . > "$2"
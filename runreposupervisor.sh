@@ -1,5 +1,5 @@
 #!/bin/bash
 
-JSON_OUTPUT=1 /root/.nvm/versions/node/v7.10.1/bin/node ./repo-supervisor/dist/cli.js $1 | jq '.' > $2
+set -o pipefail
 
-exit 0
+JSON_OUTPUT=1 /root/.nvm/versions/node/v7.10.1/bin/node ./repo-supervisor/dist/cli.js "$1" | jq '.' > "$2"
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// The subset of the SARIF 2.1.0 object model written by git-all-secrets
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool                     sarifTool                        `json:"tool"`
+	Invocations              []sarifInvocation                `json:"invocations,omitempty"`
+	OriginalURIBaseIDs       map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	VersionControlProvenance []sarifVersionControlDetails     `json:"versionControlProvenance,omitempty"`
+	Results                  []sarifResult                    `json:"results"`
+}
+
+type sarifVersionControlDetails struct {
+	RepositoryURI string                `json:"repositoryUri"`
+	RevisionID    string                `json:"revisionId,omitempty"`
+	MappedTo      sarifArtifactLocation `json:"mappedTo"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	BaselineState       string            `json:"baselineState,omitempty"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI         string        `json:"uri,omitempty"`
+	URIBaseID   string        `json:"uriBaseId,omitempty"`
+	Description *sarifMessage `json:"description,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifRuleID turns a detector name like "AWS API Key" into a SARIF rule id like "aws-api-key"
+func sarifRuleID(rule string) string {
+	return strings.Join(strings.Fields(strings.ToLower(rule)), "-")
+}
+
+// sarifURIBase returns the id of the uriBaseId that the file path of f is relative to. Every
+// repository, and every commit of it, has its own, since a run covers many repositories.
+func sarifURIBase(f Finding) string {
+	id := f.OrgOrUser + "/" + f.Repo
+	if f.Commit != "" {
+		id += "@" + f.Commit
+	}
+	return id
+}
+
+// writeSARIF writes the findings as a SARIF 2.1.0 log with one run per tool. The errors of a tool
+// are notifications in the invocation of its run. Errors listing or cloning a repository kept
+// every tool from scanning it, so those are added to every run. The file paths are relative to
+// a uriBaseId for the repository and commit they were found in, which the versionControlProvenance
+// of the run maps to the repository URL and commit SHA.
+func writeSARIF(w io.Writer, selected []Scanner, findings []Finding, errors []scanError) error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{},
+	}
+
+	for _, s := range selected {
+		run := sarifRun{
+			Tool:    sarifTool{Driver: sarifDriver{Name: s.Name(), Rules: []sarifRule{}}},
+			Results: []sarifResult{},
+		}
+		ruleIndex := make(map[string]int)
+
+		invocation := sarifInvocation{ExecutionSuccessful: true}
+		for _, e := range errors {
+			if e.Tool != "" && e.Tool != s.Name() {
+				continue
+			}
+			text := e.Stage + " failed"
+			if e.OrgOrUser != "" || e.Repo != "" {
+				text += " for " + e.OrgOrUser + "/" + e.Repo
+			}
+			invocation.ExecutionSuccessful = false
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:   "error",
+				Message: sarifMessage{Text: text + ": " + e.Error},
+			})
+		}
+		run.Invocations = []sarifInvocation{invocation}
+
+		for _, f := range findings {
+			if f.Tool != s.Name() {
+				continue
+			}
+
+			id := sarifRuleID(f.Rule)
+			idx, ok := ruleIndex[id]
+			if !ok {
+				idx = len(run.Tool.Driver.Rules)
+				ruleIndex[id] = idx
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               id,
+					Name:             f.Rule,
+					ShortDescription: sarifMessage{Text: f.Rule},
+				})
+			}
+
+			base := sarifURIBase(f)
+			if _, ok := run.OriginalURIBaseIDs[base]; !ok {
+				if run.OriginalURIBaseIDs == nil {
+					run.OriginalURIBaseIDs = make(map[string]sarifArtifactLocation)
+				}
+				description := "Checked out files of " + f.OrgOrUser + "/" + f.Repo
+				if f.Commit != "" {
+					description = f.OrgOrUser + "/" + f.Repo + " at commit " + f.Commit
+				}
+				run.OriginalURIBaseIDs[base] = sarifArtifactLocation{Description: &sarifMessage{Text: description}}
+
+				if f.RepoURL != "" {
+					run.VersionControlProvenance = append(run.VersionControlProvenance, sarifVersionControlDetails{
+						RepositoryURI: f.RepoURL,
+						RevisionID:    f.Commit,
+						MappedTo:      sarifArtifactLocation{URIBaseID: base},
+					})
+				}
+			}
+
+			loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: f.File, URIBaseID: base},
+			}}
+			if f.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+			}
+
+			props := map[string]string{"repository": f.OrgOrUser + "/" + f.Repo}
+			if len(f.Tools) > 1 {
+				props["tools"] = strings.Join(f.Tools, ",")
+			}
+
+			var fingerprints map[string]string
+			if f.Fingerprint != "" {
+				fingerprints = map[string]string{"secretFingerprint/v1": f.Fingerprint}
+			}
+
+			var baselineState string
+			if *baselineFile != "" {
+				baselineState = "new"
+				if f.Known {
+					baselineState = "unchanged"
+				}
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:              id,
+				RuleIndex:           idx,
+				Level:               "error",
+				Message:             sarifMessage{Text: f.Rule + " found in " + f.OrgOrUser + "/" + f.Repo + ": " + f.Preview},
+				Locations:           []sarifLocation{loc},
+				PartialFingerprints: fingerprints,
+				BaselineState:       baselineState,
+				Properties:          props,
+			})
+		}
+
+		log.Runs = append(log.Runs, run)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Scanner is a secret scanning backend that can be run against a cloned repository.
+// Each backend registers itself from an init function so that main.go does not
+// need to know about the individual tools.
+type Scanner interface {
+	// Name is the value used to select the scanner with -toolName. It is also the
+	// name of the directory under the results directory where external tools write their output.
+	Name() string
+
+	// Scan runs the scanner against the repository checked out at filepath and returns
+	// what it found. Scanners that go through the history only look at the commits in commits.
+	Scan(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error)
+}
+
+// commitRange is the part of the history of a repository to scan: the commits reachable from the
+// current refs but not from Since, the ref tips of the previous scan. Head is the HEAD commit of the
+// previous scan, for tools that can only start from a single commit. An empty range is the whole
+// history.
+type commitRange struct {
+	Since []string
+	Head  string
+}
+
+var scanners = make(map[string]Scanner)
+
+// registerScanner makes a scanner available to -toolName. It panics if two
+// scanners are registered under the same name.
+func registerScanner(s Scanner) {
+	if _, dup := scanners[s.Name()]; dup {
+		panic("scanner registered twice: " + s.Name())
+	}
+	scanners[s.Name()] = s
+}
+
+// scannerNames returns the names of all the registered scanners in sorted order
+func scannerNames() []string {
+	var names []string
+	for name := range scanners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectScanners turns the comma separated -toolName value into the list of scanners to run.
+// "all" selects every registered scanner.
+func selectScanners(toolName string) ([]Scanner, error) {
+	var selected []Scanner
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(toolName, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var names []string
+		if name == "all" {
+			names = scannerNames()
+		} else if _, ok := scanners[name]; ok {
+			names = []string{name}
+		} else {
+			return nil, fmt.Errorf("unknown tool %q. Please enter a comma separated list of %s or all", name, strings.Join(scannerNames(), ", "))
+		}
+
+		for _, n := range names {
+			if !seen[n] {
+				seen[n] = true
+				selected = append(selected, scanners[n])
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tool selected. Please enter a comma separated list of %s or all", strings.Join(scannerNames(), ", "))
+	}
+	return selected, nil
+}
+
+// newResultPath returns the path an external tool writes its output for a single repository to
+func newResultPath(toolname string, reponame string, orgoruser string) string {
+	name := strings.Replace(orgoruser+"_"+reponame, "/", "_", -1)
+	return filepath.Join(resultsDir(toolname), name+"_"+uuid.NewV4().String()+".txt")
+}
+
+// parseResultFile reads the output an external tool wrote to path with one of the tool parsers
+func parseResultFile(path string, parse func(r io.Reader) ([]Finding, error)) ([]Finding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parse(file)
+}
+
+// foundIssues reports whether err is the exit status 1 that a tool exits with when it finds
+// something, rather than a failure of the tool. That is only the case if it wrote output to path.
+func foundIssues(err error, path string) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 1 {
+		return false
+	}
+	info, statErr := os.Stat(path)
+	return statErr == nil && info.Size() > 0
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripANSI removes the color codes the external tools put in their output
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// scanFileLines calls fn for every line of every text file in the repository checked out at dir.
+// The .git directory and binary files are skipped.
+func scanFileLines(dir string, fn func(path string, lineno int, line string)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content, 0) != -1 {
+			return nil
+		}
+
+		relpath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		for i, line := range strings.Split(string(content), "\n") {
+			fn(relpath, i+1, strings.TrimSuffix(line, "\r"))
+		}
+		return nil
+	})
+}
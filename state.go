@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// repoState is what the state file remembers about a repository from the last time it was scanned
+type repoState struct {
+	Refs      map[string]string `json:"refs"`
+	Head      string            `json:"head,omitempty"`
+	Tools     []string          `json:"tools"`
+	PushedAt  time.Time         `json:"pushedAt,omitempty"`
+	ScannedAt time.Time         `json:"scannedAt"`
+}
+
+// stateStore is the state file that lets a run only scan what changed since the previous one.
+// Repositories are keyed by repoKey.
+type stateStore struct {
+	mu    sync.Mutex
+	path  string
+	Repos map[string]repoState `json:"repos"`
+}
+
+// state is the state file of the run, or nil without -stateFile
+var state *stateStore
+
+// loadState reads the state file at path. A missing file is an empty state, as on the first run.
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path, Repos: make(map[string]repoState)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Repos == nil {
+		s.Repos = make(map[string]repoState)
+	}
+	return s, nil
+}
+
+func (s *stateStore) get(key string) (repoState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Repos[key]
+	return st, ok
+}
+
+func (s *stateStore) set(key string, st repoState) {
+	s.mu.Lock()
+	s.Repos[key] = st
+	s.mu.Unlock()
+}
+
+// save writes the state file. It is written to a temporary file first so that an interrupted
+// run doesn't leave a truncated state behind.
+func (s *stateStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(tmp)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// repoKey identifies the repository at cloneURL by its host and full path, so that its HTTPS and
+// SSH URLs are the same repository. The whole path is used since Gitlab projects in different
+// groups can have the same subgroup and name.
+func repoKey(cloneURL string) (string, error) {
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	if err != nil {
+		return "", err
+	}
+
+	host := endpoint.Host
+	if host == "" {
+		host = "local"
+	}
+	repoPath := strings.TrimSuffix(strings.TrimSuffix(strings.TrimRight(endpoint.Path, "/"), "/.git"), ".git")
+	return path.Join(host, repoPath), nil
+}
+
+// scannerSettings returns the selected tools, along with the settings that change what they
+// find, like the thresholds of the entropy tool
+func scannerSettings() []string {
+	var tools []string
+	for _, s := range activeScanners {
+		tool := s.Name()
+		if tool == "entropy" {
+			tool += fmt.Sprintf("(b64=%g,hex=%g,min=%d)", *entropyB64Threshold, *entropyHexThreshold, *entropyMinLength)
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// previousScan returns the state of the previous scan of the repository at cloneURL. A scan with
+// only some of the tools selected now, or with other settings, doesn't count, since the other
+// tools have never seen the commits it scanned. The first tool it didn't run is returned then.
+func previousScan(cloneURL string) (st repoState, missingTool string, ok bool) {
+	if state == nil || *fullScan {
+		return repoState{}, "", false
+	}
+	key, err := repoKey(cloneURL)
+	if err != nil {
+		return repoState{}, "", false
+	}
+	st, ok = state.get(key)
+	if !ok {
+		return repoState{}, "", false
+	}
+
+	scanned := make(map[string]bool)
+	for _, tool := range st.Tools {
+		scanned[tool] = true
+	}
+	for _, tool := range scannerSettings() {
+		if !scanned[tool] {
+			return repoState{}, tool, false
+		}
+	}
+	return st, "", true
+}
+
+// scanRange returns the commits to scan in the repository at cloneURL: the ones that weren't
+// scanned by a previous run, or the whole history with -full
+func scanRange(cloneURL string) commitRange {
+	st, missingTool, ok := previousScan(cloneURL)
+	if missingTool != "" {
+		fmt.Println(cloneURL + " was scanned before without " + missingTool + " so its whole history is scanned..")
+	}
+	if !ok {
+		return commitRange{}
+	}
+
+	commits := commitRange{Head: st.Head}
+	for _, h := range st.Refs {
+		commits.Since = append(commits.Since, h)
+	}
+	return commits
+}
+
+// unchanged reports whether the repository at cloneURL wasn't pushed to since it was last scanned,
+// according to the time of the last push the provider listed it with. Such repositories don't
+// need to be cloned at all.
+func unchanged(cloneURL string, pushedAt time.Time) bool {
+	if pushedAt.IsZero() {
+		return false
+	}
+	st, _, ok := previousScan(cloneURL)
+	return ok && st.PushedAt.Equal(pushedAt)
+}
+
+// recordScan remembers the ref tips of the clone of cloneURL at dir as scanned, along with the time
+// of the last push to it the provider listed it with
+func recordScan(cloneURL string, dir string, pushedAt time.Time) error {
+	if state == nil {
+		return nil
+	}
+	key, err := repoKey(cloneURL)
+	if err != nil {
+		return err
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	st := repoState{Refs: make(map[string]string), Tools: scannerSettings(), PushedAt: pushedAt, ScannedAt: time.Now().UTC()}
+
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		// annotated tags point at a tag object, which is peeled to the commit it tags
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			c, err := tag.Commit()
+			if err != nil {
+				// a tag of a tree or blob, which has no history to scan
+				return nil
+			}
+			hash = c.Hash
+		}
+		st.Refs[ref.Name().String()] = hash.String()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if head, err := repo.Head(); err == nil {
+		st.Head = head.Hash().String()
+	}
+	state.set(key, st)
+	return nil
+}
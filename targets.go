@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// target is a git URL from the -targets file, along with the owner and name parsed from it
+// and the directory it is cloned to
+type target struct {
+	URL   string
+	Owner string
+	Name  string
+	Dir   string
+}
+
+// readTargets reads one git URL per line from path. Blank lines and lines starting with # are
+// skipped. Every target gets its own directory under dir, even when two URLs on different hosts
+// have the same owner and name.
+func readTargets(path string, dir string) ([]target, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []target
+	used := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		owner, name := parseRepoURL(line)
+		targetDir := filepath.Join(dir, owner+"_"+name)
+		for i := 2; used[targetDir]; i++ {
+			targetDir = filepath.Join(dir, owner+"_"+name+"_"+strconv.Itoa(i))
+		}
+		used[targetDir] = true
+
+		targets = append(targets, target{URL: line, Owner: owner, Name: name, Dir: targetDir})
+	}
+	return targets, scanner.Err()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type trufflehogScanner struct{}
+
+func init() {
+	registerScanner(trufflehogScanner{})
+}
+
+func (trufflehogScanner) Name() string { return "thog" }
+
+// Scan only passes the previous HEAD of commits to truffleHog, which stops at that commit on every branch
+func (trufflehogScanner) Scan(filepath string, reponame string, orgoruser string, commits commitRange) ([]Finding, error) {
+	outputFile := newResultPath("thog", reponame, orgoruser)
+	// truffleHog exits with 1 when it finds issues
+	if err := runTrufflehog(filepath, outputFile, commits.Head); err != nil && !foundIssues(err, outputFile) {
+		return nil, err
+	}
+	return parseResultFile(outputFile, func(r io.Reader) ([]Finding, error) {
+		return parseTrufflehog(r, reponame, orgoruser)
+	})
+}
+
+func runTrufflehog(filepath string, outputFile1 string, sinceCommit string) error {
+	// open the out file for writing
+	outfile, fileErr := os.OpenFile(outputFile1, os.O_CREATE|os.O_RDWR, 0644)
+	if fileErr != nil {
+		return fileErr
+	}
+	defer outfile.Close()
+
+	args := []string{"./truffleHog/truffleHog/truffleHog.py", "--regex", "--entropy=True"}
+	if sinceCommit != "" {
+		args = append(args, "--since_commit", sinceCommit)
+	}
+	cmd1 := exec.Command("python", append(args, filepath)...)
+
+	// direct stdout to the outfile
+	cmd1.Stdout = outfile
+
+	return cmd1.Run()
+}
+
+const (
+	trufflehogDelimiter = "~~~~~~~~~~~~~~~~~~~~~"
+	trufflehogHighlight = "\x1b[93m"
+	trufflehogEndColor  = "\x1b[0m"
+)
+
+// parseTrufflehog turns the blocks truffleHog prints for every issue into findings.
+// Each block has a header with the reason, date, hash, file path, branch and commit message
+// followed by the diff, in which the strings that were found are highlighted.
+func parseTrufflehog(r io.Reader, reponame string, orgoruser string) ([]Finding, error) {
+	var findings []Finding
+	var header map[string]string
+	var diff []string
+
+	flush := func() {
+		if header == nil {
+			return
+		}
+		for _, secret := range trufflehogHighlighted(strings.Join(diff, "\n")) {
+			findings = append(findings, Finding{
+				Tool:      "thog",
+				Rule:      header["Reason"],
+				OrgOrUser: orgoruser,
+				Repo:      reponame,
+				File:      header["Filepath"],
+				Commit:    header["Hash"],
+				Date:      header["Date"],
+				Secret:    secret,
+				Preview:   redact(secret),
+			})
+		}
+		header, diff = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if stripANSI(line) == trufflehogDelimiter {
+			flush()
+			header = make(map[string]string)
+			continue
+		}
+		if header == nil {
+			continue
+		}
+
+		// the header ends at the Commit line, everything after that is the diff
+		if _, ok := header["Commit"]; !ok {
+			if i := strings.Index(stripANSI(line), ": "); i != -1 {
+				plain := stripANSI(line)
+				header[plain[:i]] = plain[i+2:]
+				continue
+			}
+		}
+		diff = append(diff, line)
+	}
+	flush()
+
+	return findings, scanner.Err()
+}
+
+// trufflehogHighlighted returns the strings truffleHog highlighted in a diff
+func trufflehogHighlighted(diff string) []string {
+	var found []string
+	for {
+		start := strings.Index(diff, trufflehogHighlight)
+		if start == -1 {
+			return found
+		}
+		diff = diff[start+len(trufflehogHighlight):]
+
+		end := strings.Index(diff, trufflehogEndColor)
+		if end == -1 {
+			return found
+		}
+		if s := diff[:end]; s != "" {
+			found = append(found, s)
+		}
+		diff = diff[end+len(trufflehogEndColor):]
+	}
+}